@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// orgAuthorProperty sets the author of a subtree post, as in ox-hugo.
+const orgAuthorProperty = "EXPORT_AUTHOR"
+
+var hatenaIDRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{1,30}[A-Za-z0-9]$`)
+
+func validateMembers(members map[string]string) error {
+	for id := range members {
+		if !hatenaIDRe.MatchString(id) {
+			return fmt.Errorf("invalid Hatena ID in members: %q", id)
+		}
+	}
+	return nil
+}
+
+// entryAuthor returns the Hatena ID to attribute an entry to, or "" to
+// leave it to the authenticated user. config.Author (-author) wins over
+// postAuthor, the #+AUTHOR: of the post, and either may be a member's
+// Hatena ID or the name it is mapped to in config.Members. Without members
+// configured, #+AUTHOR: is ignored since a personal blog has no one else to
+// attribute posts to, but -author is an error.
+func entryAuthor(config *Config, postAuthor string) (string, error) {
+	author := strings.TrimSpace(config.Author)
+	if author == "" {
+		if len(config.Members) == 0 {
+			return "", nil
+		}
+		author = strings.TrimSpace(postAuthor)
+	}
+	if author == "" {
+		return "", nil
+	}
+	if len(config.Members) == 0 {
+		return "", fmt.Errorf("-author needs the members of the group blog in the config")
+	}
+
+	if strings.EqualFold(author, config.HatenaID) {
+		return config.HatenaID, nil
+	}
+	for id := range config.Members {
+		if strings.EqualFold(author, id) {
+			return id, nil
+		}
+	}
+	for id, name := range config.Members {
+		if name != "" && author == name {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("author %q is not a member of the blog", author)
+}
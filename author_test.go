@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestEntryAuthor(t *testing.T) {
+	members := map[string]string{
+		"taro":   "Taro Yamada",
+		"hanako": "山田花子",
+	}
+
+	tests := []struct {
+		name       string
+		members    map[string]string
+		flag       string
+		postAuthor string
+		expected   string
+		wantErr    bool
+	}{
+		{"personal blog ignores #+AUTHOR:", nil, "", "garaemon", "", false},
+		{"personal blog rejects -author", nil, "taro", "", "", true},
+		{"no author", members, "", "", "", false},
+		{"member ID", members, "", "taro", "taro", false},
+		{"member ID in another case", members, "", "Hanako", "hanako", false},
+		{"member name", members, "", "山田花子", "hanako", false},
+		{"the authenticated owner", members, "", "owner", "owner", false},
+		{"-author wins", members, "Taro Yamada", "hanako", "taro", false},
+		{"unknown author", members, "", "jiro", "", true},
+	}
+
+	for _, test := range tests {
+		config := &Config{HatenaID: "owner", Members: test.members, Author: test.flag}
+		author, err := entryAuthor(config, test.postAuthor)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: entryAuthor failed: %v", test.name, err)
+		} else if author != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.name, author, test.expected)
+		}
+	}
+}
+
+func TestValidateMembers(t *testing.T) {
+	if err := validateMembers(map[string]string{"taro": "", "hanako-2": "Hanako"}); err != nil {
+		t.Errorf("Valid members should not return error: %v", err)
+	}
+	for _, id := range []string{"", "1taro", "taro san", "x"} {
+		if err := validateMembers(map[string]string{id: ""}); err == nil {
+			t.Errorf("Member %q should be invalid", id)
+		}
+	}
+}
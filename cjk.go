@@ -0,0 +1,246 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var orgListItemRe = regexp.MustCompile(`^\s*(?:[-+]|\s+\*|\d+[.)])(?:\s|$)`)
+
+// isCJK reports whether r is a Chinese or Japanese character or CJK
+// punctuation, i.e. a character that is not separated from its neighbours
+// by spaces.
+func isCJK(r rune) bool {
+	switch {
+	case unicode.Is(unicode.Han, r), unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+		return true
+	case r == 'ー', r >= 0x3000 && r <= 0x303F, r >= 0xFF00 && r <= 0xFFEF:
+		// Prolonged sound mark, CJK symbols and punctuation, and fullwidth
+		// forms
+		return true
+	}
+	return false
+}
+
+// isOrgParagraphLine reports whether a line outside of blocks is plain
+// paragraph text, as opposed to a headline, keyword, table, list item,
+// drawer or fixed-width line.
+func isOrgParagraphLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	switch {
+	case trimmed == "":
+		return false
+	case isOrgHeading(line):
+		return false
+	case strings.HasPrefix(trimmed, "#"), strings.HasPrefix(trimmed, "|"):
+		return false
+	case orgFixedWidthRe.MatchString(line), orgPropertyLineRe.MatchString(line), orgListItemRe.MatchString(line):
+		return false
+	}
+	return true
+}
+
+// joinCJKLines joins the hard-wrapped lines of each paragraph into one line
+// so that Hatena does not turn the newlines into spaces or line breaks. Lines
+// are joined without a space when either side of the break is a CJK
+// character and with a single space otherwise. Blocks, tables and lists are
+// left untouched, as are lines ending in an explicit \\ line break, and a
+// footnote definition is never joined onto the line above.
+func joinCJKLines(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	var result []string
+	inBlock := false
+	inList := false
+	joinable := false
+
+	for _, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			result = append(result, line)
+			joinable = false
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if orgListItemRe.MatchString(line) {
+			inList = true
+		} else if trimmed != "" && inList && line[0] != ' ' && line[0] != '\t' {
+			// A line that is not indented ends the list
+			inList = false
+		}
+
+		if inList || !isOrgParagraphLine(line) {
+			result = append(result, line)
+			joinable = false
+			continue
+		}
+
+		// A footnote definition starts a paragraph of its own, whose
+		// wrapped lines are joined to it like any other
+		if joinable && !orgFootnoteDefRe.MatchString(line) {
+			prev := result[len(result)-1]
+			result[len(result)-1] = joinLines(prev, trimmed)
+		} else {
+			result = append(result, line)
+		}
+		joinable = !strings.HasSuffix(trimmed, `\\`)
+	}
+
+	return strings.Join(result, "\n")
+}
+
+func joinLines(prev, next string) string {
+	prev = strings.TrimRight(prev, " \t")
+	last, _ := utf8.DecodeLastRuneInString(prev)
+	first, _ := utf8.DecodeRuneInString(next)
+	if isCJK(last) || isCJK(first) {
+		return prev + next
+	}
+	return prev + " " + next
+}
+
+// orgEmphasisTags maps org emphasis markers to the HTML elements used when
+// the markers touch CJK characters.
+var orgEmphasisTags = map[rune]string{
+	'*': "strong",
+	'/': "em",
+	'_': "u",
+	'+': "del",
+	'=': "code",
+	'~': "code",
+}
+
+// isOrgEmphasisPre reports whether r may precede an opening emphasis marker
+// according to org's syntax.
+func isOrgEmphasisPre(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`-('"{`, r)
+}
+
+// isOrgEmphasisPost reports whether r may follow a closing emphasis marker
+// according to org's syntax.
+func isOrgEmphasisPost(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`-.,;:!?')}["\`, r)
+}
+
+// fixCJKEmphasis rewrites org emphasis whose markers are directly next to CJK
+// characters, such as これは*重要*です or =hatena-blog-org=ツール, which
+// org does not recognize because it expects whitespace or punctuation
+// around the markers. Such emphasis becomes inline HTML export snippets,
+// which render on Hatena where markdown's ** next to CJK characters does
+// not.
+func fixCJKEmphasis(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	inBlock := false
+	for i, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") || orgPropertyLineRe.MatchString(line) {
+			continue
+		}
+
+		prefix := ""
+		if m := orgHeadingRe.FindStringSubmatch(line); m != nil {
+			prefix = m[1] + " "
+			line = m[2]
+		}
+		lines[i] = prefix + fixCJKEmphasisInLine(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func fixCJKEmphasisInLine(line string) string {
+	runes := []rune(line)
+	var b strings.Builder
+
+	for i := 0; i < len(runes); i++ {
+		// Links and export snippets are copied as they are
+		if skip := orgInlineObjectEnd(runes, i); skip > i {
+			b.WriteString(string(runes[i:skip]))
+			i = skip - 1
+			continue
+		}
+
+		tag, isMarker := orgEmphasisTags[runes[i]]
+		if !isMarker {
+			b.WriteRune(runes[i])
+			continue
+		}
+
+		end, touchesCJK := findEmphasisEnd(runes, i)
+		if end < 0 {
+			b.WriteRune(runes[i])
+			continue
+		}
+		if !touchesCJK {
+			// Org recognizes this emphasis by itself
+			b.WriteString(string(runes[i : end+1]))
+			i = end
+			continue
+		}
+
+		body := string(runes[i+1 : end])
+		if tag == "code" {
+			b.WriteString("@@html:<code>" + html.EscapeString(body) + "</code>@@")
+		} else {
+			b.WriteString("@@html:<" + tag + ">@@" + body + "@@html:</" + tag + ">@@")
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// findEmphasisEnd returns the index of the closing marker of emphasis
+// opened at start, or -1 if there is none. Unlike org, a CJK character is
+// accepted next to the markers; touchesCJK reports whether one is.
+func findEmphasisEnd(runes []rune, start int) (end int, touchesCJK bool) {
+	marker := runes[start]
+	var pre rune = ' '
+	if start > 0 {
+		pre = runes[start-1]
+	}
+	if !isCJK(pre) && !isOrgEmphasisPre(pre) {
+		return -1, false
+	}
+	if start+1 >= len(runes) || unicode.IsSpace(runes[start+1]) || runes[start+1] == marker {
+		return -1, false
+	}
+
+	for end = start + 1; end < len(runes); end++ {
+		if runes[end] != marker || unicode.IsSpace(runes[end-1]) {
+			continue
+		}
+		var post rune = ' '
+		if end+1 < len(runes) {
+			post = runes[end+1]
+		}
+		if !isCJK(post) && !isOrgEmphasisPost(post) {
+			continue
+		}
+		return end, isCJK(pre) || isCJK(post)
+	}
+	return -1, false
+}
+
+// orgInlineObjectEnd returns the index just past a [[link]] or @@snippet@@
+// starting at i, or i if there is none.
+func orgInlineObjectEnd(runes []rune, i int) int {
+	rest := string(runes[i:])
+	var closing string
+	switch {
+	case strings.HasPrefix(rest, "[["):
+		closing = "]]"
+	case strings.HasPrefix(rest, "@@"):
+		closing = "@@"
+	default:
+		return i
+	}
+	end := strings.Index(rest[2:], closing)
+	if end < 0 {
+		return i
+	}
+	return i + utf8.RuneCountInString(rest[:2+end+len(closing)])
+}
@@ -0,0 +1,157 @@
+package main
+
+import "testing"
+
+func TestIsCJK(t *testing.T) {
+	for _, r := range "漢ひカー、。（）！" {
+		if !isCJK(r) {
+			t.Errorf("Expected %q to be CJK", r)
+		}
+	}
+	for _, r := range "aZ1 .,(한" {
+		if isCJK(r) {
+			t.Errorf("Expected %q not to be CJK", r)
+		}
+	}
+}
+
+func TestJoinCJKLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "japanese lines joined without space",
+			input:    "これは日本語の\n文章です。\n続きの文。",
+			expected: "これは日本語の文章です。続きの文。",
+		},
+		{
+			name:     "latin lines joined with space",
+			input:    "This is a\nwrapped sentence.",
+			expected: "This is a wrapped sentence.",
+		},
+		{
+			name:     "mixed boundary",
+			input:    "Emacsの\norg-modeを使う。\nUse it with\n日本語",
+			expected: "Emacsのorg-modeを使う。Use it with日本語",
+		},
+		{
+			name:     "paragraphs stay separate",
+			input:    "一つ目の\n段落。\n\n二つ目の\n段落。",
+			expected: "一つ目の段落。\n\n二つ目の段落。",
+		},
+		{
+			name:     "footnote definitions stay separate",
+			input:    "本文[fn:1]の\n続き\n[fn:1] 脚注一の\n続き\n[fn:2] 脚注二",
+			expected: "本文[fn:1]の続き\n[fn:1] 脚注一の続き\n[fn:2] 脚注二",
+		},
+		{
+			name:     "headlines and keywords untouched",
+			input:    "#+title: タイトル\n* 見出し\n本文の\n続き",
+			expected: "#+title: タイトル\n* 見出し\n本文の続き",
+		},
+		{
+			name:     "code blocks untouched",
+			input:    "説明\n#+begin_src go\nfoo\nbar\n#+end_src\n後の\n文",
+			expected: "説明\n#+begin_src go\nfoo\nbar\n#+end_src\n後の文",
+		},
+		{
+			name:     "tables untouched",
+			input:    "| a | b |\n| 1 | 2 |",
+			expected: "| a | b |\n| 1 | 2 |",
+		},
+		{
+			name:     "lists untouched",
+			input:    "- 項目の\n  続き\n- 次の\n  項目\n\n後の\n段落",
+			expected: "- 項目の\n  続き\n- 次の\n  項目\n\n後の段落",
+		},
+		{
+			name:     "explicit line break kept",
+			input:    "一行目\\\\\n二行目\n三行目",
+			expected: "一行目\\\\\n二行目三行目",
+		},
+		{
+			name:     "drawers untouched",
+			input:    ":PROPERTIES:\n:ID: abc\n:CUSTOM_ID: def\n:END:\n本文",
+			expected: ":PROPERTIES:\n:ID: abc\n:CUSTOM_ID: def\n:END:\n本文",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := joinCJKLines(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestFixCJKEmphasis(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "bold between japanese",
+			input:    "これは*重要*です",
+			expected: "これは@@html:<strong>@@重要@@html:</strong>@@です",
+		},
+		{
+			name:     "verbatim followed by japanese",
+			input:    "これは=hatena-blog-org=ツールです。",
+			expected: "これは@@html:<code>hatena-blog-org</code>@@ツールです。",
+		},
+		{
+			name:     "code is HTML escaped",
+			input:    "型は~map[string]<T>~です",
+			expected: "型は@@html:<code>map[string]&lt;T&gt;</code>@@です",
+		},
+		{
+			name:     "italic, underline and strike",
+			input:    "「/斜体/」と_下線_と+取消+",
+			expected: "「@@html:<em>@@斜体@@html:</em>@@」と@@html:<u>@@下線@@html:</u>@@と@@html:<del>@@取消@@html:</del>@@",
+		},
+		{
+			name:     "emphasis org already recognizes untouched",
+			input:    "これは *重要* です。 =code= and *bold*",
+			expected: "これは *重要* です。 =code= and *bold*",
+		},
+		{
+			name:     "markers inside recognized verbatim untouched",
+			input:    "see =a*日本*b= here",
+			expected: "see =a*日本*b= here",
+		},
+		{
+			name:     "links untouched",
+			input:    "[[https://example.com/a_b_c][リンク]]を参照",
+			expected: "[[https://example.com/a_b_c][リンク]]を参照",
+		},
+		{
+			name:     "latin words untouched",
+			input:    "a*b*c snake_case_name 1/2/3",
+			expected: "a*b*c snake_case_name 1/2/3",
+		},
+		{
+			name:     "headline",
+			input:    "** これは*重要*な見出し",
+			expected: "** これは@@html:<strong>@@重要@@html:</strong>@@な見出し",
+		},
+		{
+			name:     "src blocks and keywords untouched",
+			input:    "#+title: タイトル*強調*\n#+begin_src sh\necho 日本*語*\n#+end_src",
+			expected: "#+title: タイトル*強調*\n#+begin_src sh\necho 日本*語*\n#+end_src",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := fixCJKEmphasis(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -11,6 +11,78 @@ type Config struct {
 	HatenaID   string `json:"hatena_id"`
 	APIKey     string `json:"api_key"`
 	BlogDomain string `json:"blog_domain"`
+	// Format is the output format for the blog, "markdown" (default) or
+	// "html" for blogs using the WYSIWYG or HTML editor.
+	Format string `json:"format,omitempty"`
+	// Macros and LinkAbbrevs are global #+MACRO: and #+LINK: definitions
+	// available to every org file.
+	Macros      map[string]string `json:"macros,omitempty"`
+	LinkAbbrevs map[string]string `json:"link_abbrevs,omitempty"`
+	// JoinCJKLines joins hard-wrapped lines of a paragraph before posting.
+	JoinCJKLines bool `json:"join_cjk_lines,omitempty"`
+	// CJKEmphasis recognizes emphasis markers next to CJK characters.
+	CJKEmphasis bool `json:"cjk_emphasis,omitempty"`
+	// Math is "tex" (default), "mathjax" or "none".
+	Math string `json:"math,omitempty"`
+	// LangAliases maps org source block languages to highlighter names.
+	LangAliases map[string]string `json:"lang_aliases,omitempty"`
+	// NumberFigures numbers image and table captions.
+	NumberFigures bool `json:"number_figures,omitempty"`
+	// SpecialBlocks maps special block names to the HTML they become.
+	SpecialBlocks map[string]SpecialBlockStyle `json:"special_blocks,omitempty"`
+	// HeadingOffset shifts heading levels, e.g. 2 for org level 1 as h3.
+	HeadingOffset int `json:"heading_offset,omitempty"`
+	// EntriesFile records published entries for cross-post links; it
+	// defaults to entries.json in the config directory.
+	EntriesFile string `json:"entries_file,omitempty"`
+	// BlogDir is searched for the :ID: of id: links.
+	BlogDir string `json:"blog_dir,omitempty"`
+	// StrictLinks makes links to unpublished posts an error.
+	StrictLinks bool `json:"strict_links,omitempty"`
+	// Footnotes is the footnote style: markdown or hatena.
+	Footnotes string `json:"footnotes,omitempty"`
+	// EmbedDomains maps domains to embed as "cite" or "embed".
+	EmbedDomains map[string]string `json:"embed_domains,omitempty"`
+	// Encoding is the encoding of posts without a BOM or coding cookie,
+	// e.g. "shift_jis"; it is detected when empty.
+	Encoding string `json:"encoding,omitempty"`
+	// AutoSummary is the length of the summary generated from the content
+	// of posts without a description; 0 disables it.
+	AutoSummary int `json:"auto_summary,omitempty"`
+	// Members maps the Hatena IDs of the members of a group blog to their
+	// names, which #+AUTHOR: may use in place of the ID.
+	Members map[string]string `json:"members,omitempty"`
+	// Author is the member entries are attributed to, overriding
+	// #+AUTHOR:.
+	Author string `json:"author,omitempty"`
+}
+
+// convertOptions returns the conversion settings of the blog.
+func (c *Config) convertOptions() ConvertOptions {
+	return ConvertOptions{
+		Format:        c.Format,
+		Macros:        c.Macros,
+		LinkAbbrevs:   c.LinkAbbrevs,
+		JoinCJKLines:  c.JoinCJKLines,
+		CJKEmphasis:   c.CJKEmphasis,
+		Math:          c.Math,
+		LangAliases:   c.LangAliases,
+		NumberFigures: c.NumberFigures,
+		SpecialBlocks: c.SpecialBlocks,
+		HeadingOffset: c.HeadingOffset,
+		BlogDir:       c.BlogDir,
+		StrictLinks:   c.StrictLinks,
+		Footnotes:     c.Footnotes,
+		EmbedDomains:  c.EmbedDomains,
+	}
+}
+
+// entriesPath returns the file published entries are recorded in.
+func (c *Config) entriesPath() string {
+	if c.EntriesFile != "" {
+		return c.EntriesFile
+	}
+	return getDefaultEntriesPath()
 }
 
 func loadConfig(configFile, hatenaID, apiKey, blogDomain string) (*Config, error) {
@@ -30,15 +102,18 @@ func loadConfig(configFile, hatenaID, apiKey, blogDomain string) (*Config, error
 		if err != nil {
 			return nil, err
 		}
-		if config.HatenaID == "" {
-			config.HatenaID = fileConfig.HatenaID
+		// Credentials given on the command line take precedence; everything
+		// else comes from the default config file.
+		if config.HatenaID != "" {
+			fileConfig.HatenaID = config.HatenaID
 		}
-		if config.APIKey == "" {
-			config.APIKey = fileConfig.APIKey
+		if config.APIKey != "" {
+			fileConfig.APIKey = config.APIKey
 		}
-		if config.BlogDomain == "" {
-			config.BlogDomain = fileConfig.BlogDomain
+		if config.BlogDomain != "" {
+			fileConfig.BlogDomain = config.BlogDomain
 		}
+		return fileConfig, nil
 	}
 
 	return config, nil
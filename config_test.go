@@ -116,6 +116,29 @@ func TestValidateConfig(t *testing.T) {
 		{HatenaID: "testuser", APIKey: "testapi"},
 	}
 
+	htmlConfig := *validConfig
+	htmlConfig.Format = "html"
+	if err := validateConfig(&htmlConfig); err != nil {
+		t.Errorf("HTML format config should not return error: %v", err)
+	}
+
+	invalidFormat := *validConfig
+	invalidFormat.Format = "latex"
+	invalidConfigs = append(invalidConfigs, &invalidFormat)
+
+	invalidOffset := *validConfig
+	invalidOffset.HeadingOffset = -1
+	invalidConfigs = append(invalidConfigs, &invalidOffset)
+
+	invalidSummary := *validConfig
+	invalidSummary.AutoSummary = -1
+	invalidConfigs = append(invalidConfigs, &invalidSummary)
+
+	unknownAuthor := *validConfig
+	unknownAuthor.Members = map[string]string{"taro": "Taro Yamada"}
+	unknownAuthor.Author = "jiro"
+	invalidConfigs = append(invalidConfigs, &unknownAuthor)
+
 	for i, config := range invalidConfigs {
 		err := validateConfig(config)
 		if err == nil {
@@ -1,36 +1,191 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// ConvertOptions controls how an org file is converted for posting.
+type ConvertOptions struct {
+	// Format is the output format, either FormatMarkdown or FormatHTML.
+	// An empty value means FormatMarkdown.
+	Format string
+	// BaseDir is the directory relative paths in #+INCLUDE: and
+	// #+SETUPFILE: are resolved against.
+	BaseDir string
+	// Macros and LinkAbbrevs are global #+MACRO: and #+LINK: definitions,
+	// overridden by the ones in the document.
+	Macros      map[string]string
+	LinkAbbrevs map[string]string
+	// JoinCJKLines joins hard-wrapped paragraph lines, without a space
+	// between CJK characters.
+	JoinCJKLines bool
+	// CJKEmphasis rewrites emphasis markers next to CJK characters, which
+	// org does not recognize, into inline HTML.
+	CJKEmphasis bool
+	// Math selects how LaTeX fragments are rendered: MathTex (the default)
+	// for Hatena's [tex:] notation, MathMathJax for MathJax delimiters, or
+	// MathNone to leave them to pandoc.
+	Math string
+	// LangAliases maps org source block languages to the names Hatena's
+	// highlighter uses, in addition to defaultLangAliases.
+	LangAliases map[string]string
+	// NumberFigures prefixes image and table captions with "図 N"/"表 N".
+	NumberFigures bool
+	// SpecialBlocks styles special blocks such as #+begin_note, in addition
+	// to defaultSpecialBlocks.
+	SpecialBlocks map[string]SpecialBlockStyle
+	// HeadingOffset is added to the level of every heading, e.g. 2 to turn
+	// org's level 1 into h3.
+	HeadingOffset int
+	// Entries, when set, is used to rewrite file: and id: links to other
+	// posts into their published URLs. BlogDir is scanned for :ID:
+	// properties and defaults to BaseDir; with StrictLinks a link to an
+	// unpublished post is an error instead of a warning.
+	Entries     *EntryRegistry
+	BlogDir     string
+	StrictLinks bool
+	// Footnotes is FootnotesMarkdown (the default) or FootnotesHatena.
+	Footnotes string
+	// EmbedDomains maps domains whose standalone links are always embedded
+	// to EmbedCite or EmbedPlayer.
+	EmbedDomains map[string]string
+}
+
 func convertOrgToMarkdown(orgFilePath string) (string, error) {
-	if !fileExists(orgFilePath) {
-		return "", fmt.Errorf("org file not found: %s", orgFilePath)
+	return convertOrgFile(orgFilePath, ConvertOptions{Format: FormatMarkdown})
+}
+
+func convertOrgToHTML(orgFilePath string) (string, error) {
+	return convertOrgFile(orgFilePath, ConvertOptions{Format: FormatHTML})
+}
+
+func convertOrgFile(orgFilePath string, opts ConvertOptions) (string, error) {
+	doc, err := readOrgDocument(orgFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	if opts.BaseDir == "" {
+		opts.BaseDir = filepath.Dir(orgFilePath)
+	}
+	return convertOrgContent(doc.Content, opts)
+}
+
+// convertOrgContent converts org source text, as read from a file or taken
+// from a single subtree, into the requested output format.
+func convertOrgContent(orgContent string, opts ConvertOptions) (string, error) {
+	orgContent, err := expandOrgIncludes(orgContent, opts.BaseDir)
+	if err != nil {
+		return "", err
+	}
+	orgContent = expandOrgMacros(orgContent, opts.Macros, opts.LinkAbbrevs, time.Now())
+	// Excluded subtrees must not drive any later stage, e.g. fail a post
+	// with a link to an unpublished draft or break the single heading title
+	orgContent = pruneOrgExport(orgContent)
+	if opts.Entries != nil {
+		blogDir := opts.BlogDir
+		if blogDir == "" {
+			blogDir = opts.BaseDir
+		}
+		orgContent, err = resolveOrgCrossPostLinks(orgContent, opts.BaseDir, blogDir, opts.Entries, opts.StrictLinks)
+		if err != nil {
+			return "", err
+		}
+	}
+	orgContent = promoteSingleOrgHeading(orgContent)
+	orgContent = resolveOrgInternalLinks(orgContent)
+	orgContent = insertOrgTableOfContents(orgContent)
+	orgContent = applyOrgExportOptions(orgContent)
+	orgContent = insertOrgMoreMarker(orgContent)
+	orgContent = adjustOrgHeadingLevels(orgContent, opts.HeadingOffset)
+	if opts.Footnotes == FootnotesHatena {
+		orgContent = convertOrgFootnotes(orgContent)
+	}
+	orgContent = mapHatenaExports(orgContent)
+	orgContent = processOrgSpecialBlocks(orgContent, opts.SpecialBlocks)
+	orgContent = processOrgSrcBlocks(orgContent, opts.LangAliases)
+	orgContent = processOrgEmbeds(orgContent, opts.EmbedDomains)
+	orgContent = processOrgFigures(orgContent, opts.NumberFigures)
+	orgContent = convertOrgMath(orgContent, opts.Math, opts.Format)
+	if opts.JoinCJKLines {
+		orgContent = joinCJKLines(orgContent)
+	}
+	if opts.CJKEmphasis {
+		orgContent = fixCJKEmphasis(orgContent)
 	}
 
-	if !strings.HasSuffix(orgFilePath, ".org") {
-		return "", fmt.Errorf("file is not an org file: %s", orgFilePath)
+	switch opts.Format {
+	case "", FormatMarkdown:
+		output, err := runPandoc(opts.BaseDir, orgContent, "-f", "org", "-t", "markdown", "--wrap=preserve")
+		if err != nil {
+			return "", err
+		}
+
+		markdown := output
+		markdown = filterOrgMetadata(markdown)
+		markdown = unwrapRawExports(markdown)
+		markdown = removeVerbatimAttributes(markdown)
+		markdown = removeAttachTags(markdown)
+		markdown = removeIdAttributes(markdown)
+		return markdown, nil
+	case FormatHTML:
+		// --no-highlight keeps pandoc from emitting inline styles and
+		// sourceCode wrappers that Hatena's highlighter does not understand.
+		output, err := runPandoc(opts.BaseDir, orgContent, "-f", "org", "-t", "html", "--wrap=preserve", "--no-highlight")
+		if err != nil {
+			return "", err
+		}
+
+		htmlContent := output
+		htmlContent = removeHTMLAttachTags(htmlContent)
+		htmlContent = removeHTMLVerbatimClasses(htmlContent)
+		htmlContent = removeHTMLHeadingIDs(htmlContent)
+		htmlContent = convertHTMLCodeBlocks(htmlContent)
+		return htmlContent, nil
+	default:
+		return "", fmt.Errorf("unsupported output format: %s", opts.Format)
 	}
+}
 
-	cmd := exec.Command("pandoc", "-f", "org", "-t", "markdown", "--wrap=preserve", orgFilePath)
+// runPandoc runs pandoc in dir, or in the current directory if dir is
+// empty, with input on its standard input.
+func runPandoc(dir, input string, args ...string) (string, error) {
+	cmd := exec.Command("pandoc", args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(input)
 	output, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf("pandoc conversion failed: %v", err)
 	}
+	return string(output), nil
+}
+
+// contentTypeForFormat returns the Atom content type Hatena expects for
+// entries written in the given output format.
+func contentTypeForFormat(format string) string {
+	if format == FormatHTML {
+		return "text/html"
+	}
+	return "text/x-markdown"
+}
 
-	markdown := string(output)
-	markdown = filterOrgMetadata(markdown)
-	markdown = removeVerbatimAttributes(markdown)
-	markdown = removeAttachTags(markdown)
-	markdown = removeIdAttributes(markdown)
-	return markdown, nil
+func validateFormat(format string) error {
+	switch format {
+	case "", FormatMarkdown, FormatHTML:
+		return nil
+	}
+	return fmt.Errorf("unsupported output format: %s (expected %q or %q)", format, FormatMarkdown, FormatHTML)
 }
 
 func filterOrgMetadata(markdown string) string {
@@ -71,6 +226,47 @@ func removeIdAttributes(markdown string) string {
 	return re.ReplaceAllString(markdown, "")
 }
 
+func removeHTMLAttachTags(htmlContent string) string {
+	// Remove ATTACH tags like <span class="tag" tag-name="ATTACH"><span class="smallcaps">ATTACH</span></span>
+	re := regexp.MustCompile(`\s*<span class="tag" tag-name="ATTACH"><span class="smallcaps">ATTACH</span></span>`)
+	return re.ReplaceAllString(htmlContent, "")
+}
+
+func removeHTMLVerbatimClasses(htmlContent string) string {
+	return strings.ReplaceAll(htmlContent, `<code class="verbatim">`, "<code>")
+}
+
+func removeHTMLHeadingIDs(htmlContent string) string {
+	// Hatena assigns its own heading IDs, so drop the ones pandoc generates
+	re := regexp.MustCompile(`<(h[1-6]) id="[^"]*"`)
+	return re.ReplaceAllString(htmlContent, "<$1")
+}
+
+func convertHTMLCodeBlocks(htmlContent string) string {
+	// Rewrite <pre class="go"><code>...</code></pre> into the markup Hatena's
+	// own editor produces so that its syntax highlighter picks it up.
+	re := regexp.MustCompile(`(?s)<pre(?: class="([^"]*)")?><code>(.*?)</code></pre>`)
+	return re.ReplaceAllStringFunc(htmlContent, func(block string) string {
+		m := re.FindStringSubmatch(block)
+		lang := ""
+		for _, class := range strings.Fields(m[1]) {
+			if class != "sourceCode" && class != "numberLines" && class != "example" {
+				lang = class
+				break
+			}
+		}
+		if lang == "" {
+			return fmt.Sprintf(`<pre class="code" data-unlink>%s</pre>`, m[2])
+		}
+		return fmt.Sprintf(`<pre class="code lang-%s" data-lang="%s" data-unlink>%s</pre>`, lang, lang, m[2])
+	})
+}
+
+// warnf reports a problem that does not stop the conversion.
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
@@ -80,66 +276,19 @@ func fileExists(filename string) bool {
 }
 
 func extractTitleFromOrg(orgFilePath string) (string, error) {
-	file, err := os.Open(orgFilePath)
+	doc, err := readOrgDocument(orgFilePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open org file: %v", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(strings.ToLower(line), "#+title:") {
-			title := strings.TrimSpace(line[8:])
-			if title != "" {
-				return title, nil
-			}
-		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("failed to read org file: %v", err)
+		return "", err
 	}
-
-	return "Untitled", nil
+	return doc.postTitle(), nil
 }
 
 func extractCategoriesFromOrg(orgFilePath string) ([]string, error) {
-	file, err := os.Open(orgFilePath)
+	doc, err := readOrgDocument(orgFilePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open org file: %v", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(strings.ToLower(line), "#+filetags:") {
-			tagsString := strings.TrimSpace(line[11:])
-			if tagsString != "" {
-				var categories []string
-
-				// Split by both spaces and colons
-				parts := strings.FieldsFunc(tagsString, func(c rune) bool {
-					return c == ' ' || c == '\t' || c == ':'
-				})
-
-				for _, part := range parts {
-					part = strings.TrimSpace(part)
-					if part != "" {
-						categories = append(categories, part)
-					}
-				}
-				return categories, nil
-			}
-		}
+		return nil, err
 	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read org file: %v", err)
-	}
-
-	return []string{}, nil
+	return append([]string{}, doc.FileTags...), nil
 }
 
 func getAbsPath(path string) (string, error) {
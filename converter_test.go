@@ -4,6 +4,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -127,6 +128,16 @@ func TestExtractTitleFromOrg(t *testing.T) {
 			orgContent:    "#+title:\n\nContent here",
 			expectedTitle: "Untitled",
 		},
+		{
+			name:          "single top-level heading",
+			orgContent:    "#+filetags: :go:\n* Heading Title :tag:\nBody\n** Section",
+			expectedTitle: "Heading Title",
+		},
+		{
+			name:          "several top-level headings",
+			orgContent:    "* First\n* Second",
+			expectedTitle: "Untitled",
+		},
 	}
 
 	for _, tt := range tests {
@@ -401,6 +412,123 @@ func TestRemoveIdAttributes(t *testing.T) {
 	}
 }
 
+func TestConvertOrgToHTML(t *testing.T) {
+	if !isPandocAvailable() {
+		t.Skip("pandoc not available, skipping test")
+	}
+
+	tmpFile := filepath.Join(os.TempDir(), "test_html.org")
+	orgContent := `* Test Title
+
+#+begin_src go
+fmt.Println("hello")
+#+end_src
+`
+	err := os.WriteFile(tmpFile, []byte(orgContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	htmlContent, err := convertOrgToHTML(tmpFile)
+	if err != nil {
+		t.Fatalf("convertOrgToHTML failed: %v", err)
+	}
+
+	if !strings.Contains(htmlContent, `<pre class="code lang-go" data-lang="go" data-unlink>`) {
+		t.Errorf("Expected Hatena code block markup, got:\n%s", htmlContent)
+	}
+	if strings.Contains(htmlContent, "style=") {
+		t.Errorf("Expected no inline styles, got:\n%s", htmlContent)
+	}
+}
+
+func TestConvertOrgFileUnsupportedFormat(t *testing.T) {
+	tmpFile := filepath.Join(os.TempDir(), "test_format.org")
+	err := os.WriteFile(tmpFile, []byte("test"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile)
+
+	_, err = convertOrgFile(tmpFile, ConvertOptions{Format: "latex"})
+	if err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestConvertHTMLCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "code block with language",
+			input:    "<pre class=\"go\"><code>fmt.Println(&quot;hi&quot;)\n</code></pre>",
+			expected: "<pre class=\"code lang-go\" data-lang=\"go\" data-unlink>fmt.Println(&quot;hi&quot;)\n</pre>",
+		},
+		{
+			name:     "code block with line numbers",
+			input:    "<pre class=\"python numberLines\"><code>print(1)</code></pre>",
+			expected: "<pre class=\"code lang-python\" data-lang=\"python\" data-unlink>print(1)</pre>",
+		},
+		{
+			name:     "code block without language",
+			input:    "<pre><code>plain text\n</code></pre>",
+			expected: "<pre class=\"code\" data-unlink>plain text\n</pre>",
+		},
+		{
+			name:     "inline code untouched",
+			input:    "<p>use <code>go build</code></p>",
+			expected: "<p>use <code>go build</code></p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := convertHTMLCodeBlocks(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRemoveHTMLHeadingIDs(t *testing.T) {
+	input := "<h1 id=\"29302AC1-B779-4976-B6E3-ACE995038F26\">Section</h1>\n<h2 id=\"sub\">Sub</h2>\n<p id=\"keep\">text</p>"
+	expected := "<h1>Section</h1>\n<h2>Sub</h2>\n<p id=\"keep\">text</p>"
+
+	result := removeHTMLHeadingIDs(input)
+	if result != expected {
+		t.Errorf("Expected:\n%q\nGot:\n%q", expected, result)
+	}
+}
+
+func TestRemoveHTMLAttachTags(t *testing.T) {
+	input := "<h1>Section <span class=\"tag\" tag-name=\"ATTACH\"><span class=\"smallcaps\">ATTACH</span></span></h1>"
+	expected := "<h1>Section</h1>"
+
+	result := removeHTMLAttachTags(input)
+	if result != expected {
+		t.Errorf("Expected:\n%q\nGot:\n%q", expected, result)
+	}
+}
+
+func TestContentTypeForFormat(t *testing.T) {
+	tests := map[string]string{
+		"":             "text/x-markdown",
+		FormatMarkdown: "text/x-markdown",
+		FormatHTML:     "text/html",
+	}
+
+	for format, expected := range tests {
+		if got := contentTypeForFormat(format); got != expected {
+			t.Errorf("contentTypeForFormat(%q) = %q, expected %q", format, got, expected)
+		}
+	}
+}
+
 func isPandocAvailable() bool {
 	_, err := exec.LookPath("pandoc")
 	return err == nil
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OrgDocument is the file-level metadata of an org file, read in a single
+// pass and shared by the stages that need it.
+type OrgDocument struct {
+	// Path is the file the document was read from, if any.
+	Path string
+	// Content is the whole file.
+	Content string
+	Lines   []string
+
+	// Title joins all #+TITLE: lines with spaces, as org does.
+	Title       string
+	FileTags    []string
+	Date        string
+	Author      string
+	Description string
+	Language    string
+	// Keywords holds the values of every keyword outside of blocks, by
+	// upper-case name, in document order.
+	Keywords map[string][]string
+	// Properties are the file-level property drawer and #+PROPERTY: lines.
+	Properties map[string]string
+}
+
+// readOrgDocument reads and parses an org file. Unlike bufio.Scanner,
+// reading the file whole puts no limit on the length of a line.
+func readOrgDocument(orgFilePath string) (*OrgDocument, error) {
+	if !fileExists(orgFilePath) {
+		return nil, fmt.Errorf("org file not found: %s", orgFilePath)
+	}
+
+	if !strings.HasSuffix(orgFilePath, ".org") {
+		return nil, fmt.Errorf("file is not an org file: %s", orgFilePath)
+	}
+
+	data, err := os.ReadFile(orgFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read org file: %v", err)
+	}
+	content, err := decodeText(data, "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", orgFilePath, err)
+	}
+
+	doc := parseOrgDocument(content)
+	doc.Path = orgFilePath
+	return doc, nil
+}
+
+// parseOrgPostDocument parses a post as org-export sees it, with
+// #+INCLUDE: and #+SETUPFILE: expanded relative to opts.BaseDir, so that
+// the #+AUTHOR: or #+FILETAGS: of a shared setup file count too.
+func parseOrgPostDocument(content string, opts ConvertOptions) (*OrgDocument, error) {
+	expanded, err := expandOrgIncludes(content, opts.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	return parseOrgDocument(expanded), nil
+}
+
+func parseOrgDocument(content string) *OrgDocument {
+	doc := &OrgDocument{
+		Content:    content,
+		Lines:      strings.Split(content, "\n"),
+		Keywords:   map[string][]string{},
+		Properties: map[string]string{},
+	}
+
+	// The file-level drawer comes first, possibly after comments
+	i := 0
+	for i < len(doc.Lines) {
+		trimmed := strings.TrimSpace(doc.Lines[i])
+		if trimmed != "" && (!strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "#+")) {
+			break
+		}
+		i++
+	}
+	parseOrgSectionHeader(doc.Lines, i, doc.Properties)
+
+	inBlock := false
+	for _, line := range doc.Lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		m := orgKeywordRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key, value := strings.ToUpper(m[1]), m[2]
+		doc.Keywords[key] = append(doc.Keywords[key], value)
+
+		switch key {
+		case "TITLE":
+			doc.Title = joinOrgKeyword(doc.Title, value, " ")
+		case "FILETAGS":
+			doc.FileTags = append(doc.FileTags, splitOrgTags(value)...)
+		case "DATE":
+			doc.Date = value
+		case "AUTHOR":
+			doc.Author = joinOrgKeyword(doc.Author, value, " ")
+		case "DESCRIPTION":
+			doc.Description = joinOrgKeyword(doc.Description, value, "\n")
+		case "LANGUAGE":
+			doc.Language = value
+		case "PROPERTY":
+			// Names are case-insensitive, and upper-cased like drawer keys
+			name, propertyValue, _ := strings.Cut(value, " ")
+			name = strings.ToUpper(name)
+			propertyValue = strings.TrimSpace(propertyValue)
+			if strings.HasSuffix(name, "+") {
+				// NAME+ appends to an earlier value
+				name = strings.TrimSuffix(name, "+")
+				doc.Properties[name] = joinOrgKeyword(doc.Properties[name], propertyValue, " ")
+			} else {
+				doc.Properties[name] = propertyValue
+			}
+		}
+	}
+
+	return doc
+}
+
+func joinOrgKeyword(current, value, sep string) string {
+	if value == "" {
+		return current
+	}
+	if current == "" {
+		return value
+	}
+	return current + sep + value
+}
+
+// postTitle returns the title of the post: #+TITLE:, or a single top-level
+// heading left after pruneOrgExport (see promoteSingleOrgHeading), or
+// "Untitled".
+func (d *OrgDocument) postTitle() string {
+	if d.Title != "" {
+		return d.Title
+	}
+	if top := singleOrgTopHeading(strings.Split(pruneOrgExport(d.Content), "\n")); top != nil {
+		return top.Title
+	}
+	return "Untitled"
+}
+
+// keyword returns the last value of a keyword, as org uses for settings.
+func (d *OrgDocument) keyword(name string) string {
+	values := d.Keywords[strings.ToUpper(name)]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
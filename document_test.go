@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOrgDocument(t *testing.T) {
+	content := `# -*- mode: org -*-
+:PROPERTIES:
+:ID:       0F6C2A36-0D7C-4E1B-9D0B-3C8F3E1A2B4C
+:END:
+#+TITLE: A long title
+#+TITLE: split over two lines
+#+FILETAGS: :go:emacs:
+#+filetags: blog
+#+DATE: <2024-01-02 Tue>
+#+AUTHOR: garaemon
+#+DESCRIPTION: First line
+#+DESCRIPTION: second line
+#+LANGUAGE: ja
+#+PROPERTY: header-args :results output
+#+PROPERTY: header-args+ :exports both
+#+property: description From the keyword
+#+EYECATCH: images/top.png
+#+begin_src org
+#+TITLE: not this one
+#+end_src
+
+* Heading
+`
+	doc := parseOrgDocument(content)
+
+	if doc.Title != "A long title split over two lines" {
+		t.Errorf("Unexpected title %q", doc.Title)
+	}
+	if !reflect.DeepEqual(doc.FileTags, []string{"go", "emacs", "blog"}) {
+		t.Errorf("Unexpected file tags %v", doc.FileTags)
+	}
+	if doc.Date != "<2024-01-02 Tue>" || doc.Author != "garaemon" || doc.Language != "ja" {
+		t.Errorf("Unexpected date, author or language: %q %q %q", doc.Date, doc.Author, doc.Language)
+	}
+	if doc.Description != "First line\nsecond line" {
+		t.Errorf("Unexpected description %q", doc.Description)
+	}
+	if doc.Properties["ID"] != "0F6C2A36-0D7C-4E1B-9D0B-3C8F3E1A2B4C" {
+		t.Errorf("Unexpected file ID %q", doc.Properties["ID"])
+	}
+	if doc.Properties["HEADER-ARGS"] != ":results output :exports both" {
+		t.Errorf("Unexpected header-args property %q", doc.Properties["HEADER-ARGS"])
+	}
+	if doc.Properties["DESCRIPTION"] != "From the keyword" {
+		t.Errorf("Unexpected description property %q", doc.Properties["DESCRIPTION"])
+	}
+	if doc.keyword("eyecatch") != "images/top.png" {
+		t.Errorf("Unexpected custom keyword %q", doc.keyword("eyecatch"))
+	}
+	if len(doc.Keywords["TITLE"]) != 2 {
+		t.Errorf("Keywords inside blocks should be ignored, got %v", doc.Keywords["TITLE"])
+	}
+}
+
+func TestOrgDocumentPostTitle(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"#+TITLE: Title\n* Heading", "Title"},
+		{"* Heading\n** Sub", "Heading"},
+		{"* Heading\n* Notes :noexport:\n* COMMENT Draft", "Heading"},
+		{"text", "Untitled"},
+	}
+	for _, tt := range tests {
+		if title := parseOrgDocument(tt.content).postTitle(); title != tt.expected {
+			t.Errorf("Expected title %q for %q, got %q", tt.expected, tt.content, title)
+		}
+	}
+}
+
+func TestParseOrgPostDocument(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"setup/common.org": "#+AUTHOR: hanako\n#+FILETAGS: :blog:\n",
+	})
+
+	doc, err := parseOrgPostDocument("#+SETUPFILE: setup/common.org\n#+FILETAGS: :go:\n#+TITLE: Post\n", ConvertOptions{BaseDir: dir})
+	if err != nil {
+		t.Fatalf("parseOrgPostDocument failed: %v", err)
+	}
+	if doc.Author != "hanako" {
+		t.Errorf("Expected the author from the setup file, got %q", doc.Author)
+	}
+	if !reflect.DeepEqual(doc.FileTags, []string{"blog", "go"}) {
+		t.Errorf("Expected file tags [blog go], got %v", doc.FileTags)
+	}
+
+	if _, err := parseOrgPostDocument("#+SETUPFILE: missing.org\n", ConvertOptions{BaseDir: dir}); err == nil {
+		t.Error("A missing setup file should be an error")
+	}
+}
+
+func TestReadOrgDocumentLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "long.org")
+	longLine := "[[data:image/png;base64," + strings.Repeat("A", 200*1024) + "]]"
+	content := longLine + "\n#+TITLE: After a long line\n#+FILETAGS: :tag:\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+
+	title, err := extractTitleFromOrg(path)
+	if err != nil {
+		t.Fatalf("extractTitleFromOrg failed: %v", err)
+	}
+	if title != "After a long line" {
+		t.Errorf("Unexpected title %q", title)
+	}
+	categories, err := extractCategoriesFromOrg(path)
+	if err != nil {
+		t.Fatalf("extractCategoriesFromOrg failed: %v", err)
+	}
+	if !reflect.DeepEqual(categories, []string{"tag"}) {
+		t.Errorf("Unexpected categories %v", categories)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// EmbedCite is a blog card, [URL:embed:cite].
+	EmbedCite = "cite"
+	// EmbedPlayer is a media embed such as a video player, [URL:embed].
+	EmbedPlayer = "embed"
+)
+
+var (
+	orgAttrHatenaRe     = regexp.MustCompile(`(?i)^\s*#\+ATTR_HATENA:[ \t]*(.*?)[ \t]*$`)
+	orgStandaloneLinkRe = regexp.MustCompile(`^\s*(?:\[\[(https?://[^\[\]\s]+)\](?:\[[^\[\]]*\])?\]|(https?://\S+))[ \t]*$`)
+)
+
+// mediaEmbedDomains are the sites Hatena embeds as players or posts rather
+// than blog cards.
+var mediaEmbedDomains = []string{
+	"youtube.com",
+	"youtu.be",
+	"twitter.com",
+	"x.com",
+	"gist.github.com",
+	"speakerdeck.com",
+}
+
+func validateEmbedDomains(domains map[string]string) error {
+	for domain, style := range domains {
+		if style != EmbedCite && style != EmbedPlayer {
+			return fmt.Errorf("unsupported embed style for %s: %s (expected %q or %q)", domain, style, EmbedCite, EmbedPlayer)
+		}
+	}
+	return nil
+}
+
+// processOrgEmbeds turns a link that stands alone in its paragraph into
+// Hatena's embed notation when it has #+ATTR_HATENA: :embed, or when its
+// domain is in domains, which maps a domain to EmbedCite or EmbedPlayer.
+// ":embed t" picks the style from the domain, ":embed cite" and ":embed
+// embed" choose one, and ":embed nil" keeps the link as it is.
+func processOrgEmbeds(orgContent string, domains map[string]string) string {
+	lines := strings.Split(orgContent, "\n")
+	var result []string
+	inBlock := false
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			result = append(result, line)
+			continue
+		}
+
+		// #+ATTR_HATENA: may be among other affiliated keywords
+		start := i
+		embed := ""
+		for i < len(lines) && orgAffiliatedRe.MatchString(lines[i]) {
+			if m := orgAttrHatenaRe.FindStringSubmatch(lines[i]); m != nil {
+				if value, ok := parseOrgAttributes(m[1])["embed"]; ok {
+					embed = value
+				}
+			}
+			i++
+		}
+		if i == len(lines) {
+			result = append(result, lines[start:]...)
+			break
+		}
+
+		m := orgStandaloneLinkRe.FindStringSubmatch(lines[i])
+		standalone := m != nil && isParagraphBoundary(lines, start-1) && isParagraphBoundary(lines, i+1)
+		if !standalone {
+			if i == start {
+				result = append(result, lines[i])
+			} else {
+				// Keep the keywords and look at the line after them again
+				result = append(result, lines[start:i]...)
+				i--
+			}
+			continue
+		}
+
+		link := m[1]
+		if link == "" {
+			link = m[2]
+		}
+		style := embedStyle(link, embed, domains)
+		if style == "" {
+			result = append(result, lines[start:i+1]...)
+			continue
+		}
+
+		notation := "[" + link + ":embed]"
+		if style == EmbedCite {
+			notation = "[" + link + ":embed:cite]"
+		}
+		// Other affiliated keywords are dropped along with the link
+		result = append(result, "#+begin_export html", notation, "#+end_export")
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// isParagraphBoundary reports whether line i does not continue a
+// paragraph: it is blank, out of range, a headline or a keyword.
+func isParagraphBoundary(lines []string, i int) bool {
+	if i < 0 || i >= len(lines) {
+		return true
+	}
+	trimmed := strings.TrimSpace(lines[i])
+	return trimmed == "" || isOrgHeading(lines[i]) || strings.HasPrefix(trimmed, "#+")
+}
+
+// embedStyle decides how link is embedded, or returns "" to keep it as a
+// link. Without an explicit style the configured domains decide, falling
+// back to mediaEmbedDomains for :embed t.
+func embedStyle(link, embed string, domains map[string]string) string {
+	host := ""
+	if u, err := url.Parse(link); err == nil {
+		host = strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	}
+
+	switch strings.ToLower(embed) {
+	case "nil", "no":
+		return ""
+	case EmbedCite:
+		return EmbedCite
+	case EmbedPlayer:
+		return EmbedPlayer
+	}
+
+	for domain, style := range domains {
+		if matchesDomain(host, domain) {
+			return style
+		}
+	}
+	if embed == "" {
+		return ""
+	}
+	for _, domain := range mediaEmbedDomains {
+		if matchesDomain(host, domain) {
+			return EmbedPlayer
+		}
+	}
+	return EmbedCite
+}
+
+func matchesDomain(host, domain string) bool {
+	domain = strings.TrimPrefix(strings.ToLower(domain), "www.")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
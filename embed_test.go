@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestProcessOrgEmbeds(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		domains  map[string]string
+		expected string
+	}{
+		{
+			name:     "blog card from attribute",
+			input:    "intro\n\n#+ATTR_HATENA: :embed t\n[[https://example.com/post][Post]]\n\nafter",
+			expected: "intro\n\n#+begin_export html\n[https://example.com/post:embed:cite]\n#+end_export\n\nafter",
+		},
+		{
+			name:     "media domain from attribute",
+			input:    "#+ATTR_HATENA: :embed t\nhttps://www.youtube.com/watch?v=abc",
+			expected: "#+begin_export html\n[https://www.youtube.com/watch?v=abc:embed]\n#+end_export",
+		},
+		{
+			name:     "explicit style",
+			input:    "#+NAME: video\n#+ATTR_HATENA: :embed cite\n[[https://youtu.be/abc]]",
+			expected: "#+begin_export html\n[https://youtu.be/abc:embed:cite]\n#+end_export",
+		},
+		{
+			name:     "configured domain",
+			input:    "[[https://speakerdeck.com/u/talk]]\n\n[[https://example.com]]",
+			domains:  map[string]string{"speakerdeck.com": EmbedPlayer},
+			expected: "#+begin_export html\n[https://speakerdeck.com/u/talk:embed]\n#+end_export\n\n[[https://example.com]]",
+		},
+		{
+			name:     "configured domain from attribute",
+			input:    "#+ATTR_HATENA: :embed t\n[[https://speakerdeck.com/u/talk]]\n\n#+ATTR_HATENA: :embed t\n[[https://youtu.be/abc]]",
+			domains:  map[string]string{"speakerdeck.com": EmbedPlayer, "youtu.be": EmbedCite},
+			expected: "#+begin_export html\n[https://speakerdeck.com/u/talk:embed]\n#+end_export\n\n#+begin_export html\n[https://youtu.be/abc:embed:cite]\n#+end_export",
+		},
+		{
+			name:     "attribute disables configured domain",
+			input:    "#+ATTR_HATENA: :embed nil\n[[https://youtu.be/abc]]",
+			domains:  map[string]string{"youtu.be": EmbedPlayer},
+			expected: "#+ATTR_HATENA: :embed nil\n[[https://youtu.be/abc]]",
+		},
+		{
+			name:     "links inside paragraphs untouched",
+			input:    "See\n[[https://youtu.be/abc]]\nfor details",
+			domains:  map[string]string{"youtu.be": EmbedPlayer},
+			expected: "See\n[[https://youtu.be/abc]]\nfor details",
+		},
+		{
+			name:     "keywords before other elements kept",
+			input:    "#+ATTR_HATENA: :embed t\n| a |",
+			expected: "#+ATTR_HATENA: :embed t\n| a |",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := processOrgEmbeds(tt.input, tt.domains)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidateEmbedDomains(t *testing.T) {
+	if err := validateEmbedDomains(map[string]string{"youtube.com": EmbedPlayer, "example.com": EmbedCite}); err != nil {
+		t.Errorf("Valid embed domains should not return error: %v", err)
+	}
+	if err := validateEmbedDomains(map[string]string{"youtube.com": "card"}); err == nil {
+		t.Error("Unknown embed style should return error")
+	}
+}
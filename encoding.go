@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var codingCookieRe = regexp.MustCompile(`-\*-.*?\bcoding:[ \t]*([\w.-]+).*?-\*-`)
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// emacsCodings maps Emacs coding systems, without their -unix, -dos or -mac
+// suffix, to encodings. A nil encoding leaves it to be detected.
+var emacsCodings = map[string]encoding.Encoding{
+	"undecided": nil,
+	"auto":      nil,
+
+	"utf-8":                unicode.UTF8,
+	"utf8":                 unicode.UTF8,
+	"utf-8-emacs":          unicode.UTF8,
+	"utf-8-with-signature": unicode.UTF8,
+	"utf-8-auto":           unicode.UTF8,
+	"utf-8-hfs":            unicode.UTF8,
+	"prefer-utf-8":         unicode.UTF8,
+	"mule-utf-8":           unicode.UTF8,
+
+	"utf-16":                  unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	"utf-16le":                unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be":                unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"utf-16le-with-signature": unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM),
+	"utf-16be-with-signature": unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM),
+
+	// The Shift_JIS decoder also reads the CP932 extensions Windows writes
+	"sjis":               japanese.ShiftJIS,
+	"shift_jis":          japanese.ShiftJIS,
+	"shift-jis":          japanese.ShiftJIS,
+	"japanese-shift-jis": japanese.ShiftJIS,
+	"cp932":              japanese.ShiftJIS,
+	"japanese-cp932":     japanese.ShiftJIS,
+	"windows-31j":        japanese.ShiftJIS,
+	"ms932":              japanese.ShiftJIS,
+
+	"euc-jp":            japanese.EUCJP,
+	"eucjp":             japanese.EUCJP,
+	"euc-japan":         japanese.EUCJP,
+	"euc-japan-1990":    japanese.EUCJP,
+	"japanese-iso-8bit": japanese.EUCJP,
+	"euc-jis-2004":      japanese.EUCJP,
+
+	"iso-2022-jp": japanese.ISO2022JP,
+	"junet":       japanese.ISO2022JP,
+
+	"latin-1":     charmap.ISO8859_1,
+	"iso-latin-1": charmap.ISO8859_1,
+}
+
+// decodeText returns data as UTF-8 text with LF line endings. The encoding
+// is taken from a byte order mark, an Emacs "-*- coding: ... -*-" cookie on
+// the first line (or the second, after a #! line), then the encoding
+// argument, and is otherwise UTF-8 if data is valid UTF-8, or else guessed
+// between EUC-JP and Shift_JIS.
+func decodeText(data []byte, name string) (string, error) {
+	var enc encoding.Encoding
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		data = data[len(utf8BOM):]
+		enc = unicode.UTF8
+	case bytes.HasPrefix(data, utf16LEBOM):
+		data = data[len(utf16LEBOM):]
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case bytes.HasPrefix(data, utf16BEBOM):
+		data = data[len(utf16BEBOM):]
+		enc = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	}
+	var err error
+	if enc == nil {
+		cookie := findCodingCookie(data)
+		if enc, err = lookupEncoding(cookie); err != nil {
+			return "", fmt.Errorf("unknown coding %q: %v", cookie, err)
+		}
+	}
+	if enc == nil {
+		if enc, err = lookupEncoding(name); err != nil {
+			return "", err
+		}
+	}
+	if enc == nil {
+		enc = guessEncoding(data)
+	}
+
+	if enc != unicode.UTF8 {
+		decoded, err := enc.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode: %v", err)
+		}
+		data = bytes.TrimPrefix(decoded, utf8BOM)
+	}
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n"), nil
+}
+
+// findCodingCookie returns the coding: of an Emacs file variables line.
+func findCodingCookie(data []byte) string {
+	lines := bytes.SplitN(data, []byte("\n"), 3)
+	for i, line := range lines {
+		if i == 2 || (i == 1 && !bytes.HasPrefix(lines[0], []byte("#!"))) {
+			break
+		}
+		if m := codingCookieRe.FindSubmatch(line); m != nil {
+			return string(m[1])
+		}
+	}
+	return ""
+}
+
+// lookupEncoding returns the encoding called name, an Emacs coding system
+// such as japanese-shift-jis-dos or a WHATWG label such as windows-1252. It
+// returns nil for names, and the empty name, that leave the encoding to be
+// detected.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, suffix := range []string{"-unix", "-dos", "-mac"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	if name == "" {
+		return nil, nil
+	}
+	if enc, ok := emacsCodings[name]; ok {
+		return enc, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+	return enc, nil
+}
+
+func validateEncoding(name string) error {
+	_, err := lookupEncoding(name)
+	return err
+}
+
+// guessEncoding tells UTF-8 from the Japanese legacy encodings. Text that
+// is well-formed EUC-JP is taken as EUC-JP, since Shift_JIS kana lead bytes
+// (0x81-0x9F) are not valid there; anything else is Shift_JIS.
+func guessEncoding(data []byte) encoding.Encoding {
+	if utf8.Valid(data) {
+		return unicode.UTF8
+	}
+	if isEUCJP(data) {
+		return japanese.EUCJP
+	}
+	return japanese.ShiftJIS
+}
+
+func isEUCJP(data []byte) bool {
+	isTrail := func(i int) bool { return i < len(data) && data[i] >= 0xA1 && data[i] <= 0xFE }
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c < 0x80:
+		case c == 0x8E:
+			// Half-width katakana
+			if !isTrail(i + 1) {
+				return false
+			}
+			i++
+		case c == 0x8F:
+			// JIS X 0212
+			if !isTrail(i+1) || !isTrail(i+2) {
+				return false
+			}
+			i += 2
+		case c >= 0xA1 && c <= 0xFE:
+			if !isTrail(i + 1) {
+				return false
+			}
+			i++
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+var (
+	// "#+TITLE: こんにちは" in the legacy Japanese encodings
+	shiftJISTitle = []byte("#+TITLE: \x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd")
+	eucJPTitle    = []byte("#+TITLE: \xa4\xb3\xa4\xf3\xa4\xcb\xa4\xc1\xa4\xcf")
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		encoding string
+		expected string
+	}{
+		{"UTF-8", []byte("#+TITLE: こんにちは\n"), "", "#+TITLE: こんにちは\n"},
+		{"BOM and CRLF", []byte("\xef\xbb\xbf#+TITLE: T\r\nbody\r\n"), "", "#+TITLE: T\nbody\n"},
+		{"lone CR", []byte("a\rb"), "", "a\nb"},
+		{"UTF-8 cookie", []byte("# -*- mode: org; coding: utf-8-unix -*-\n"), "shift_jis", "# -*- mode: org; coding: utf-8-unix -*-\n"},
+		{"detected Shift_JIS", shiftJISTitle, "", "#+TITLE: こんにちは"},
+		{"detected EUC-JP", eucJPTitle, "", "#+TITLE: こんにちは"},
+		{"declared EUC-JP", eucJPTitle, "euc-jp", "#+TITLE: こんにちは"},
+		{"CP932 extension", []byte("\x87\x40"), "cp932", "①"},
+		{
+			name:     "coding cookie",
+			data:     append([]byte("# -*- coding: japanese-shift-jis-dos -*-\r\n"), shiftJISTitle...),
+			encoding: "euc-jp",
+			expected: "# -*- coding: japanese-shift-jis-dos -*-\n#+TITLE: こんにちは",
+		},
+		{
+			name:     "coding cookie after #!",
+			data:     append([]byte("#!/bin/sh\n# -*- coding: euc-jp -*-\n"), eucJPTitle...),
+			expected: "#!/bin/sh\n# -*- coding: euc-jp -*-\n#+TITLE: こんにちは",
+		},
+		{"UTF-16LE BOM", []byte("\xff\xfeO\x00K\x00\r\x00\n\x00"), "", "OK\n"},
+		{"UTF-16BE BOM", []byte("\xfe\xff\x00O\x00K"), "", "OK"},
+	}
+
+	for _, test := range tests {
+		text, err := decodeText(test.data, test.encoding)
+		if err != nil {
+			t.Errorf("%s: decodeText failed: %v", test.name, err)
+		} else if text != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.name, text, test.expected)
+		}
+	}
+}
+
+func TestDecodeTextUnknownEncoding(t *testing.T) {
+	if _, err := decodeText([]byte("text"), "no-such-encoding"); err == nil {
+		t.Error("An unknown encoding should be an error")
+	}
+	if _, err := decodeText([]byte("# -*- coding: no-such-coding -*-\n"), ""); err == nil {
+		t.Error("An unknown coding cookie should be an error")
+	}
+}
+
+func TestLookupEncoding(t *testing.T) {
+	tests := map[string]encoding.Encoding{
+		"":                       nil,
+		"undecided-unix":         nil,
+		"UTF-8":                  unicode.UTF8,
+		"utf-8-emacs-unix":       unicode.UTF8,
+		"utf-8-with-signature":   unicode.UTF8,
+		"sjis":                   japanese.ShiftJIS,
+		"japanese-shift-jis-dos": japanese.ShiftJIS,
+		"cp932-dos":              japanese.ShiftJIS,
+		"japanese-iso-8bit-unix": japanese.EUCJP,
+		"euc-japan-mac":          japanese.EUCJP,
+		"iso-2022-jp":            japanese.ISO2022JP,
+		"iso-latin-1":            charmap.ISO8859_1,
+		"windows-1252":           charmap.Windows1252,
+	}
+	for name, expected := range tests {
+		enc, err := lookupEncoding(name)
+		if err != nil {
+			t.Errorf("lookupEncoding(%q) failed: %v", name, err)
+		} else if enc != expected {
+			t.Errorf("lookupEncoding(%q) = %v, expected %v", name, enc, expected)
+		}
+	}
+	if err := validateEncoding("no-such-encoding"); err == nil {
+		t.Error("An unknown encoding should not validate")
+	}
+}
+
+func TestReadOrgDocumentShiftJIS(t *testing.T) {
+	orgFile := filepath.Join(t.TempDir(), "old.org")
+	data := append(append([]byte{}, shiftJISTitle...), "\r\n#+FILETAGS: :\x93\xfa\x8b\x4c:\r\n"...)
+	if err := os.WriteFile(orgFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := readOrgDocument(orgFile)
+	if err != nil {
+		t.Fatalf("readOrgDocument failed: %v", err)
+	}
+	if doc.Title != "こんにちは" {
+		t.Errorf("Unexpected title %q", doc.Title)
+	}
+	if len(doc.FileTags) != 1 || doc.FileTags[0] != "日記" {
+		t.Errorf("Unexpected file tags %q", doc.FileTags)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var orgCrossPostLinkRe = regexp.MustCompile(`\[\[(file|id):([^\[\]]+)\](?:\[([^\[\]]*)\])?\]`)
+
+// PublishedEntry records a posted org file or subtree so that later posts
+// can link to it.
+type PublishedEntry struct {
+	// File is the absolute path of the org file.
+	File string `json:"file"`
+	// ID is the :ID: property of the subtree, or of the file for a whole-file
+	// post, if it has one.
+	ID string `json:"id,omitempty"`
+	// Subtree is set when the entry was posted from a subtree of File.
+	Subtree bool   `json:"subtree,omitempty"`
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	EditURL string `json:"edit_url,omitempty"`
+}
+
+// EntryRegistry is the list of published entries, kept in entries.json in
+// the config directory.
+type EntryRegistry struct {
+	Entries []PublishedEntry `json:"entries"`
+}
+
+func getDefaultEntriesPath() string {
+	return filepath.Join(getConfigDir(), "entries.json")
+}
+
+// loadEntryRegistry reads the registry at path; a missing file is an empty
+// registry.
+func loadEntryRegistry(path string) (*EntryRegistry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &EntryRegistry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read entries file: %v", err)
+	}
+
+	var registry EntryRegistry
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil, fmt.Errorf("failed to parse entries file: %v", err)
+	}
+	return &registry, nil
+}
+
+func (r *EntryRegistry) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create entries directory: %v", err)
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal entries: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write entries file: %v", err)
+	}
+	return nil
+}
+
+// record adds entry, replacing an earlier record of the same file or
+// subtree.
+func (r *EntryRegistry) record(entry PublishedEntry) {
+	for i, e := range r.Entries {
+		if e.File != entry.File || e.Subtree != entry.Subtree {
+			continue
+		}
+		if !entry.Subtree || (entry.ID != "" && e.ID == entry.ID) || (entry.ID == "" && e.Title == entry.Title) {
+			r.Entries[i] = entry
+			return
+		}
+	}
+	r.Entries = append(r.Entries, entry)
+}
+
+func (r *EntryRegistry) lookupID(id string) *PublishedEntry {
+	for i, e := range r.Entries {
+		if e.ID == id {
+			return &r.Entries[i]
+		}
+	}
+	return nil
+}
+
+// lookupFile returns the entry of a whole-file post, or with heading set,
+// of the subtree post with that title, falling back to the file.
+func (r *EntryRegistry) lookupFile(file, heading string) *PublishedEntry {
+	var whole *PublishedEntry
+	for i, e := range r.Entries {
+		if e.File != file {
+			continue
+		}
+		if heading != "" && e.Subtree && e.Title == heading {
+			return &r.Entries[i]
+		}
+		if !e.Subtree {
+			whole = &r.Entries[i]
+		}
+	}
+	return whole
+}
+
+// orgIDTarget is the file, and heading if any, that has an :ID: property.
+type orgIDTarget struct {
+	File    string
+	Heading string
+}
+
+// skippedBlogDirs are the directories buildOrgIDIndex does not descend
+// into, besides hidden ones such as .git.
+var skippedBlogDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+}
+
+// buildOrgIDIndex scans the .org files under dir once and maps the :ID: of
+// each file and heading to where it is. The first file or heading with an
+// ID wins.
+func buildOrgIDIndex(dir string) map[string]orgIDTarget {
+	index := map[string]orgIDTarget{}
+	add := func(id string, target orgIDTarget) {
+		if _, ok := index[id]; id != "" && !ok {
+			index[id] = target
+		}
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(d.Name(), ".") || skippedBlogDirs[d.Name()]) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".org") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		content, err := decodeText(data, "")
+		if err != nil {
+			return nil
+		}
+		if absPath, err := filepath.Abs(path); err == nil {
+			path = absPath
+		}
+		doc := parseOrgDocument(content)
+		add(doc.Properties["ID"], orgIDTarget{File: path})
+		for _, h := range parseOrgHeadings(doc.Lines) {
+			add(h.Properties["ID"], orgIDTarget{File: path, Heading: h.Title})
+		}
+		return nil
+	})
+	return index
+}
+
+// resolveOrgCrossPostLinks rewrites file: links to other org files and id:
+// links into the URLs those posts were published at. id: links are looked
+// up in the registry and then by scanning blogDir, at most once, for the
+// :ID: property.
+// Links to posts that have not been published are warned about, or with
+// strict set, are an error.
+func resolveOrgCrossPostLinks(orgContent, baseDir, blogDir string, registry *EntryRegistry, strict bool) (string, error) {
+	lines := strings.Split(orgContent, "\n")
+	var unresolved []string
+	var idIndex map[string]orgIDTarget
+	inBlock := false
+
+	for i, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		lines[i] = orgCrossPostLinkRe.ReplaceAllStringFunc(line, func(link string) string {
+			m := orgCrossPostLinkRe.FindStringSubmatch(link)
+			kind, target, description := m[1], m[2], m[3]
+
+			var entry *PublishedEntry
+			if kind == "id" {
+				entry = registry.lookupID(target)
+				if entry == nil {
+					if idIndex == nil {
+						idIndex = buildOrgIDIndex(blogDir)
+					}
+					if location, found := idIndex[target]; found {
+						entry = registry.lookupFile(location.File, location.Heading)
+					}
+				}
+			} else {
+				path, search, _ := strings.Cut(target, "::")
+				if !strings.HasSuffix(path, ".org") {
+					// Images and other files are left to pandoc
+					return link
+				}
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(baseDir, path)
+				}
+				if absPath, err := filepath.Abs(path); err == nil {
+					path = absPath
+				}
+				entry = registry.lookupFile(path, strings.TrimPrefix(search, "*"))
+			}
+
+			if entry == nil || entry.URL == "" {
+				unresolved = append(unresolved, kind+":"+target)
+				// Keep the text without a broken local link
+				if description != "" {
+					return description
+				}
+				return target
+			}
+			if description == "" {
+				description = entry.Title
+			}
+			return "[[" + entry.URL + "][" + description + "]]"
+		})
+	}
+
+	if len(unresolved) > 0 {
+		if strict {
+			return "", fmt.Errorf("links to unpublished posts: %s", strings.Join(unresolved, ", "))
+		}
+		for _, link := range unresolved {
+			warnf("link to unpublished post %s", link)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEntryRegistrySaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "entries.json")
+
+	registry, err := loadEntryRegistry(path)
+	if err != nil {
+		t.Fatalf("Loading a missing registry should not fail: %v", err)
+	}
+	if len(registry.Entries) != 0 {
+		t.Errorf("Expected empty registry, got %v", registry.Entries)
+	}
+
+	registry.record(PublishedEntry{File: "/blog/a.org", Title: "A", URL: "https://example.com/entry/a1"})
+	registry.record(PublishedEntry{File: "/blog/a.org", Title: "A", URL: "https://example.com/entry/a2"})
+	registry.record(PublishedEntry{File: "/blog/b.org", ID: "x", Subtree: true, Title: "B1", URL: "https://example.com/entry/b1"})
+	registry.record(PublishedEntry{File: "/blog/b.org", ID: "y", Subtree: true, Title: "B2", URL: "https://example.com/entry/b2"})
+	if err := registry.save(path); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	loaded, err := loadEntryRegistry(path)
+	if err != nil {
+		t.Fatalf("loadEntryRegistry failed: %v", err)
+	}
+	if len(loaded.Entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %v", loaded.Entries)
+	}
+	if e := loaded.lookupFile("/blog/a.org", ""); e == nil || e.URL != "https://example.com/entry/a2" {
+		t.Errorf("Expected the re-posted entry, got %v", e)
+	}
+	if e := loaded.lookupID("y"); e == nil || e.Title != "B2" {
+		t.Errorf("Expected entry B2 for ID y, got %v", e)
+	}
+}
+
+func TestResolveOrgCrossPostLinks(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"2024/prev.org":  ":PROPERTIES:\n:ID: file-id\n:END:\n#+TITLE: Previous\n",
+		"blog.org":       "* Post\n:PROPERTIES:\n:ID: heading-id\n:END:\n",
+		"draft/next.org": "#+TITLE: Next\n",
+	})
+	registry := &EntryRegistry{Entries: []PublishedEntry{
+		{File: filepath.Join(dir, "2024/prev.org"), Title: "Previous", URL: "https://example.com/entry/prev"},
+		{File: filepath.Join(dir, "blog.org"), Subtree: true, Title: "Post", URL: "https://example.com/entry/post"},
+	}}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "file link",
+			input:    "See [[file:2024/prev.org][previous post]].",
+			expected: "See [[https://example.com/entry/prev][previous post]].",
+		},
+		{
+			name:     "file link without description",
+			input:    "[[file:2024/prev.org::*Some heading]]",
+			expected: "[[https://example.com/entry/prev][Previous]]",
+		},
+		{
+			name:     "subtree by heading",
+			input:    "[[file:blog.org::*Post][it]]",
+			expected: "[[https://example.com/entry/post][it]]",
+		},
+		{
+			name:     "id of a file found by scanning",
+			input:    "[[id:file-id][前回]]",
+			expected: "[[https://example.com/entry/prev][前回]]",
+		},
+		{
+			name:     "id of a heading found by scanning",
+			input:    "[[id:heading-id]]",
+			expected: "[[https://example.com/entry/post][Post]]",
+		},
+		{
+			name:     "other files untouched",
+			input:    "[[file:images/a.png]] [[https://example.com]]",
+			expected: "[[file:images/a.png]] [[https://example.com]]",
+		},
+		{
+			name:     "unpublished post keeps the text",
+			input:    "[[file:draft/next.org][next]] and [[id:unknown]]",
+			expected: "next and unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := resolveOrgCrossPostLinks(tt.input, dir, dir, registry, false)
+			if err != nil {
+				t.Fatalf("resolveOrgCrossPostLinks failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+
+	_, err := resolveOrgCrossPostLinks("[[file:draft/next.org][next]]", dir, dir, registry, true)
+	if err == nil || !strings.Contains(err.Error(), "draft/next.org") {
+		t.Errorf("Expected an error naming the unpublished post, got %v", err)
+	}
+}
+
+func TestBuildOrgIDIndex(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"a.org":                  ":PROPERTIES:\n:ID: file-id\n:END:\n* Post\n:PROPERTIES:\n:ID: heading-id\n:END:\n",
+		"b.org":                  "* Copy\n:PROPERTIES:\n:ID: heading-id\n:END:\n",
+		".git/x.org":             "* Git\n:PROPERTIES:\n:ID: git-id\n:END:\n",
+		"node_modules/pkg/y.org": "* Module\n:PROPERTIES:\n:ID: module-id\n:END:\n",
+		"notes.txt":              ":PROPERTIES:\n:ID: text-id\n:END:\n",
+	})
+
+	index := buildOrgIDIndex(dir)
+	expected := map[string]orgIDTarget{
+		"file-id":    {File: filepath.Join(dir, "a.org")},
+		"heading-id": {File: filepath.Join(dir, "a.org"), Heading: "Post"},
+	}
+	if !reflect.DeepEqual(index, expected) {
+		t.Errorf("Expected %v, got %v", expected, index)
+	}
+}
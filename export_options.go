@@ -0,0 +1,501 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	orgFixedWidthRe  = regexp.MustCompile(`^\s*:(?: |$)`)
+	orgCommentHeadRe = regexp.MustCompile(`^COMMENT(?:\s|$)`)
+	orgDrawerStartRe = regexp.MustCompile(`^\s*:([\w-]+):\s*$`)
+	orgDrawerEndRe   = regexp.MustCompile(`(?i)^\s*:END:\s*$`)
+)
+
+// orgExportOptions holds the export settings org-export reads from
+// #+OPTIONS, #+SELECT_TAGS, #+EXCLUDE_TAGS and #+TODO lines.
+type orgExportOptions struct {
+	// TOC is the table of contents depth: 0 for none, -1 for all levels.
+	TOC int
+	// Num is the section numbering depth: 0 for none, -1 for all levels.
+	Num        int
+	Todo       bool
+	Tags       bool
+	Priority   bool
+	FixedWidth bool
+
+	// Planning exports SCHEDULED, DEADLINE and CLOSED lines (p:).
+	Planning bool
+	// Tasks is "t", "nil", "todo" or "done" for tasks:, or "" when
+	// TaskKeywords lists the keywords of the tasks to export.
+	Tasks        string
+	TaskKeywords []string
+	// Drawers lists the drawers to export (d:), or with DrawersNot, those
+	// to leave out; AllDrawers exports every drawer.
+	AllDrawers bool
+	Drawers    []string
+	DrawersNot bool
+
+	SelectTags   []string
+	ExcludeTags  []string
+	TodoKeywords []string
+	// DoneKeywords are the TodoKeywords that mark a finished task.
+	DoneKeywords []string
+}
+
+// defaultOrgExportOptions follows org's defaults, except that the table of
+// contents and section numbers are opt-in so that existing posts keep their
+// layout.
+func defaultOrgExportOptions() orgExportOptions {
+	return orgExportOptions{
+		Todo:         true,
+		Tags:         true,
+		Priority:     false,
+		FixedWidth:   true,
+		Tasks:        "t",
+		Drawers:      []string{"LOGBOOK"},
+		DrawersNot:   true,
+		SelectTags:   []string{"export"},
+		ExcludeTags:  []string{"noexport"},
+		TodoKeywords: []string{"TODO", "DONE"},
+		DoneKeywords: []string{"DONE"},
+	}
+}
+
+// parseOrgExportOptions collects the export settings from every keyword
+// line outside of blocks.
+func parseOrgExportOptions(lines []string) orgExportOptions {
+	opts := defaultOrgExportOptions()
+	var todoKeywords, doneKeywords []string
+	inBlock := false
+
+	for _, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		m := orgKeywordRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		switch strings.ToUpper(m[1]) {
+		case "OPTIONS":
+			for _, item := range splitOrgOptions(m[2]) {
+				// The key may itself be ":" as in "::nil", so look for the
+				// separator after the first character.
+				sep := strings.Index(item[1:], ":")
+				if sep < 0 {
+					continue
+				}
+				opts.set(item[:sep+1], item[sep+2:])
+			}
+		case "SELECT_TAGS":
+			opts.SelectTags = splitOrgTags(m[2])
+		case "EXCLUDE_TAGS":
+			opts.ExcludeTags = splitOrgTags(m[2])
+		case "TODO", "SEQ_TODO", "TYP_TODO":
+			// Keywords after "|", or else the last one, are done states
+			fields := strings.Fields(m[2])
+			doneFrom := len(fields) - 1
+			for i, field := range fields {
+				if field == "|" {
+					doneFrom = i
+				}
+			}
+			for i, keyword := range fields {
+				if keyword == "|" {
+					continue
+				}
+				// Strip fast-access keys such as TODO(t) or WAIT(w@/!)
+				if j := strings.Index(keyword, "("); j > 0 {
+					keyword = keyword[:j]
+				}
+				todoKeywords = append(todoKeywords, keyword)
+				if i >= doneFrom {
+					doneKeywords = append(doneKeywords, keyword)
+				}
+			}
+		}
+	}
+
+	if len(todoKeywords) > 0 {
+		opts.TodoKeywords = todoKeywords
+		opts.DoneKeywords = doneKeywords
+	}
+	return opts
+}
+
+// splitOrgOptions splits an #+OPTIONS: value into its items, keeping lists
+// such as tasks:("TODO" "WAIT") and quoted strings together.
+func splitOrgOptions(value string) []string {
+	var items []string
+	var current strings.Builder
+	depth, quoted := 0, false
+	for _, r := range value {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case (r == ' ' || r == '\t') && depth == 0:
+			if current.Len() > 0 {
+				items = append(items, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		items = append(items, current.String())
+	}
+	return items
+}
+
+// parseOrgOptionList reads a list value such as ("TODO" "WAIT") or
+// (not "LOGBOOK"). not reports the leading not.
+func parseOrgOptionList(value string) (items []string, not bool) {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "("), ")")
+	for _, field := range strings.Fields(value) {
+		if field == "not" && len(items) == 0 && !not {
+			not = true
+			continue
+		}
+		items = append(items, strings.Trim(field, `"`))
+	}
+	return items, not
+}
+
+func (o *orgExportOptions) set(key, value string) {
+	enabled := value != "nil"
+	switch key {
+	case "toc":
+		o.TOC = parseOrgDepth(value)
+	case "num":
+		o.Num = parseOrgDepth(value)
+	case "todo":
+		o.Todo = enabled
+	case "tags":
+		o.Tags = enabled
+	case "pri":
+		o.Priority = enabled
+	case ":":
+		o.FixedWidth = enabled
+	case "p":
+		o.Planning = enabled
+	case "tasks":
+		o.Tasks, o.TaskKeywords = strings.Trim(value, `"`), nil
+		if strings.HasPrefix(value, "(") {
+			o.Tasks = ""
+			o.TaskKeywords, _ = parseOrgOptionList(value)
+		} else if o.Tasks != "nil" && o.Tasks != "todo" && o.Tasks != "done" {
+			o.Tasks = "t"
+		}
+	case "d":
+		o.AllDrawers, o.Drawers, o.DrawersNot = value != "nil", nil, false
+		if strings.HasPrefix(value, "(") {
+			o.AllDrawers = false
+			o.Drawers, o.DrawersNot = parseOrgOptionList(value)
+		}
+	}
+}
+
+// keepsTask reports whether a headline with the TODO keyword todo is
+// exported under tasks:.
+func (o orgExportOptions) keepsTask(todo string) bool {
+	switch o.Tasks {
+	case "t":
+		return true
+	case "nil":
+		return false
+	case "todo":
+		return !containsString(o.DoneKeywords, todo)
+	case "done":
+		return containsString(o.DoneKeywords, todo)
+	}
+	return containsString(o.TaskKeywords, todo)
+}
+
+// keepsDrawer reports whether the drawer called name is exported under d:.
+func (o orgExportOptions) keepsDrawer(name string) bool {
+	if o.AllDrawers {
+		return true
+	}
+	listed := false
+	for _, drawer := range o.Drawers {
+		if strings.EqualFold(drawer, name) {
+			listed = true
+		}
+	}
+	return listed != o.DrawersNot
+}
+
+// headlineTodo returns the TODO keyword a headline starts with, if any.
+func (o orgExportOptions) headlineTodo(line string) string {
+	m := orgHeadingRe.FindStringSubmatch(line)
+	if m == nil {
+		return ""
+	}
+	for _, keyword := range o.TodoKeywords {
+		if m[2] == keyword || strings.HasPrefix(m[2], keyword+" ") {
+			return keyword
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func parseOrgDepth(value string) int {
+	if value == "nil" {
+		return 0
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return n
+	}
+	return -1
+}
+
+func hasAnyTag(tags []string, wanted []string) bool {
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCommentHeading reports whether h is a COMMENT headline. The title is
+// checked after the document's own TODO keywords, which parseOrgHeadline
+// does not know, so that * WAIT COMMENT is recognized too.
+func (o orgExportOptions) isCommentHeading(h *orgHeading) bool {
+	title := h.Title
+	for _, keyword := range o.TodoKeywords {
+		if title == keyword || strings.HasPrefix(title, keyword+" ") {
+			title = strings.TrimSpace(title[len(keyword):])
+			title = orgPriorityRe.ReplaceAllString(title, "")
+			break
+		}
+	}
+	return orgCommentHeadRe.MatchString(title)
+}
+
+// isExcludedHeading reports whether pruneOrgExport drops h along with an
+// ancestor or itself: a COMMENT headline, one with an exclude tag, or a
+// task that tasks: leaves out. lines are the lines h was parsed from.
+func (o orgExportOptions) isExcludedHeading(h *orgHeading, lines []string) bool {
+	for cur := h; cur != nil; cur = cur.Parent {
+		if o.isCommentHeading(cur) || hasAnyTag(cur.Tags, o.ExcludeTags) {
+			return true
+		}
+		if todo := o.headlineTodo(lines[cur.start]); todo != "" && !o.keepsTask(todo) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneOrgExport removes what org-export leaves out, before any other
+// stage sees it: COMMENT and excluded subtrees, subtrees outside the select
+// tags, tasks excluded by tasks:, and planning lines and drawers unless p:
+// and d: export them.
+func pruneOrgExport(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	opts := parseOrgExportOptions(lines)
+	headings := parseOrgHeadings(lines)
+
+	keep := make([]bool, len(lines))
+	for i := range keep {
+		keep[i] = true
+	}
+	drop := func(from, to int) {
+		for i := from; i < to; i++ {
+			keep[i] = false
+		}
+	}
+
+	if selected := selectedHeadings(headings, opts.SelectTags); selected != nil {
+		for i, h := range headings {
+			if selected[h] {
+				continue
+			}
+			sectionEnd := h.end
+			if i+1 < len(headings) && headings[i+1].start < sectionEnd {
+				sectionEnd = headings[i+1].start
+			}
+			drop(h.start, sectionEnd)
+		}
+	}
+
+	for _, h := range headings {
+		if opts.isCommentHeading(h) || hasAnyTag(h.Tags, opts.ExcludeTags) {
+			drop(h.start, h.end)
+		}
+		if todo := opts.headlineTodo(lines[h.start]); todo != "" && !opts.keepsTask(todo) {
+			drop(h.start, h.end)
+		}
+		if !opts.Planning && h.start+1 < len(lines) && orgPlanningRe.MatchString(lines[h.start+1]) {
+			drop(h.start+1, h.start+2)
+		}
+	}
+
+	inBlock := false
+	for i := 0; i < len(lines); i++ {
+		if isOrgBlockBoundary(lines[i], &inBlock) || inBlock {
+			continue
+		}
+		m := orgDrawerStartRe.FindStringSubmatch(lines[i])
+		if m == nil || strings.EqualFold(m[1], "END") || strings.EqualFold(m[1], "PROPERTIES") {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !orgDrawerEndRe.MatchString(lines[end]) && !isOrgHeading(lines[end]) {
+			end++
+		}
+		if end == len(lines) || isOrgHeading(lines[end]) {
+			// Not a drawer without its :END:
+			continue
+		}
+		if !opts.keepsDrawer(m[1]) {
+			drop(i, end+1)
+		}
+		i = end
+	}
+
+	var result []string
+	for i, line := range lines {
+		if keep[i] {
+			result = append(result, line)
+		}
+	}
+	return strings.Join(result, "\n")
+}
+
+// applyOrgExportOptions rewrites headlines the way org-export would once
+// pruneOrgExport has run: TODO keywords, priorities, tags and section
+// numbers follow #+OPTIONS, as do fixed-width lines. It runs after the
+// stages that look headlines up by their text. Inline options such as ^
+// are left to pandoc, which reads the same #+OPTIONS line.
+func applyOrgExportOptions(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	opts := parseOrgExportOptions(lines)
+	headings := parseOrgHeadings(lines)
+
+	var result []string
+	var counters []int
+	inBlock := false
+	headingIndex := 0
+	for _, line := range lines {
+		if !isOrgBlockBoundary(line, &inBlock) && !inBlock && isOrgHeading(line) {
+			h := headings[headingIndex]
+			headingIndex++
+			counters = nextSectionNumber(counters, h.Level)
+			line = rewriteOrgHeadline(line, h, opts, counters)
+		} else if !inBlock && !opts.FixedWidth && orgFixedWidthRe.MatchString(line) {
+			continue
+		}
+		result = append(result, line)
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// selectedHeadings returns the headlines to keep when some headline carries
+// a select tag: the tagged subtrees and their ancestors. It returns nil when
+// no select tag is used.
+func selectedHeadings(headings []*orgHeading, selectTags []string) map[*orgHeading]bool {
+	var selected map[*orgHeading]bool
+	for _, h := range headings {
+		for cur := h; cur != nil; cur = cur.Parent {
+			if !hasAnyTag(cur.Tags, selectTags) {
+				continue
+			}
+			if selected == nil {
+				selected = map[*orgHeading]bool{}
+			}
+			// Keep the headline and every ancestor up to the root
+			for anc := h; anc != nil; anc = anc.Parent {
+				selected[anc] = true
+			}
+			break
+		}
+	}
+	return selected
+}
+
+func nextSectionNumber(counters []int, level int) []int {
+	for len(counters) < level {
+		counters = append(counters, 0)
+	}
+	counters = counters[:level]
+	counters[level-1]++
+	return counters
+}
+
+func rewriteOrgHeadline(line string, h *orgHeading, opts orgExportOptions, counters []int) string {
+	m := orgHeadingRe.FindStringSubmatch(line)
+	text := m[2]
+
+	var tags string
+	if tm := orgHeadingTagsRe.FindStringSubmatchIndex(text); tm != nil {
+		tags = text[tm[2]:tm[3]]
+		text = strings.TrimSpace(text[:tm[0]])
+	}
+
+	var todo string
+	for _, keyword := range opts.TodoKeywords {
+		if text == keyword || strings.HasPrefix(text, keyword+" ") {
+			todo = keyword
+			text = strings.TrimSpace(text[len(keyword):])
+			break
+		}
+	}
+
+	var priority string
+	if pm := orgPriorityRe.FindString(text); pm != "" {
+		priority = strings.TrimSpace(pm)
+		text = text[len(pm):]
+	}
+
+	if opts.Num != 0 && (opts.Num < 0 || h.Level <= opts.Num) && !isUnnumbered(h) {
+		numbers := make([]string, len(counters))
+		for i, n := range counters {
+			numbers[i] = strconv.Itoa(n)
+		}
+		text = fmt.Sprintf("%s %s", strings.Join(numbers, "."), text)
+	}
+
+	parts := []string{m[1]}
+	if todo != "" && opts.Todo {
+		parts = append(parts, todo)
+	}
+	if priority != "" && opts.Priority {
+		parts = append(parts, priority)
+	}
+	if text != "" {
+		parts = append(parts, text)
+	}
+	if tags != "" && opts.Tags {
+		parts = append(parts, tags)
+	}
+	return strings.Join(parts, " ")
+}
+
+func isUnnumbered(h *orgHeading) bool {
+	value, ok := h.Properties["UNNUMBERED"]
+	return ok && value != "nil"
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOrgExportOptions(t *testing.T) {
+	lines := strings.Split(`#+OPTIONS: toc:2 num:t ^:{}
+#+options: todo:nil tags:nil pri:t ::nil
+#+EXCLUDE_TAGS: private draft
+#+SELECT_TAGS: publish
+#+TODO: TODO(t) WAIT(w@/!) | DONE(d) CANCELED(c)`, "\n")
+
+	opts := parseOrgExportOptions(lines)
+
+	if opts.TOC != 2 {
+		t.Errorf("Expected toc depth 2, got %d", opts.TOC)
+	}
+	if opts.Num != -1 {
+		t.Errorf("Expected num -1 (all levels), got %d", opts.Num)
+	}
+	if opts.Todo || opts.Tags || !opts.Priority || opts.FixedWidth {
+		t.Errorf("Unexpected boolean options: %+v", opts)
+	}
+	if !reflect.DeepEqual(opts.ExcludeTags, []string{"private", "draft"}) {
+		t.Errorf("Unexpected exclude tags: %v", opts.ExcludeTags)
+	}
+	if !reflect.DeepEqual(opts.SelectTags, []string{"publish"}) {
+		t.Errorf("Unexpected select tags: %v", opts.SelectTags)
+	}
+	if !reflect.DeepEqual(opts.TodoKeywords, []string{"TODO", "WAIT", "DONE", "CANCELED"}) {
+		t.Errorf("Unexpected TODO keywords: %v", opts.TodoKeywords)
+	}
+	if !reflect.DeepEqual(opts.DoneKeywords, []string{"DONE", "CANCELED"}) {
+		t.Errorf("Unexpected done keywords: %v", opts.DoneKeywords)
+	}
+}
+
+func TestParseOrgExportOptionsLists(t *testing.T) {
+	opts := parseOrgExportOptions([]string{
+		`#+OPTIONS: tasks:("TODO" "WAIT") d:(not "LOGBOOK" "NOTES") p:t`,
+		"#+TODO: TODO WAIT DONE",
+	})
+	if opts.Tasks != "" || !reflect.DeepEqual(opts.TaskKeywords, []string{"TODO", "WAIT"}) {
+		t.Errorf("Unexpected tasks: %q %v", opts.Tasks, opts.TaskKeywords)
+	}
+	if opts.AllDrawers || !opts.DrawersNot || !reflect.DeepEqual(opts.Drawers, []string{"LOGBOOK", "NOTES"}) {
+		t.Errorf("Unexpected drawers: %+v", opts)
+	}
+	if !opts.Planning {
+		t.Error("p:t should export planning lines")
+	}
+	if !reflect.DeepEqual(opts.DoneKeywords, []string{"DONE"}) {
+		t.Errorf("Without |, the last keyword should be done, got %v", opts.DoneKeywords)
+	}
+}
+
+func TestApplyOrgExportOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "noexport subtree removed",
+			input:    "* Public\ntext\n** Private notes :noexport:\nsecret\n*** Deeper\nmore secret\n* Next\nvisible",
+			expected: "* Public\ntext\n* Next\nvisible",
+		},
+		{
+			name:     "COMMENT headline removed",
+			input:    "* COMMENT Draft ideas\nsecret\n* TODO COMMENT Also hidden\nsecret\n* Kept\nvisible",
+			expected: "* Kept\nvisible",
+		},
+		{
+			name:     "custom exclude tags",
+			input:    "#+EXCLUDE_TAGS: private\n* Notes :private:\nsecret\n* Kept :noexport:\nvisible",
+			expected: "#+EXCLUDE_TAGS: private\n* Kept :noexport:\nvisible",
+		},
+		{
+			name:     "select tags keep tagged subtrees and ancestors",
+			input:    "#+SELECT_TAGS: publish\nintro\n* Parent\nparent text\n** Child :publish:\nchild text\n*** Grandchild\ngrandchild text\n** Sibling\nsibling text\n* Other\nother text",
+			expected: "#+SELECT_TAGS: publish\nintro\n* Parent\nparent text\n** Child :publish:\nchild text\n*** Grandchild\ngrandchild text",
+		},
+		{
+			name:     "todo, priority and tags stripped",
+			input:    "#+OPTIONS: todo:nil tags:nil\n* TODO [#A] Write post :blog:\nbody",
+			expected: "#+OPTIONS: todo:nil tags:nil\n* Write post\nbody",
+		},
+		{
+			name:     "priority stripped by default",
+			input:    "* DONE [#B] Finished :blog:",
+			expected: "* DONE Finished :blog:",
+		},
+		{
+			name:     "custom todo keywords stripped",
+			input:    "#+TODO: WAIT | CANCELED\n#+OPTIONS: todo:nil\n* WAIT Review\n* CANCELED Dropped",
+			expected: "#+TODO: WAIT | CANCELED\n#+OPTIONS: todo:nil\n* Review\n* Dropped",
+		},
+		{
+			name:     "section numbers",
+			input:    "#+OPTIONS: num:2\n* Intro\n** Background\n*** Detail\n** Goal\n* Method",
+			expected: "#+OPTIONS: num:2\n* 1 Intro\n** 1.1 Background\n*** Detail\n** 1.2 Goal\n* 2 Method",
+		},
+		{
+			name:     "fixed-width lines removed",
+			input:    "#+OPTIONS: ::nil\ntext\n: fixed width\n:\n#+begin_src sh\n: kept in block\n#+end_src",
+			expected: "#+OPTIONS: ::nil\ntext\n#+begin_src sh\n: kept in block\n#+end_src",
+		},
+		{
+			name:     "COMMENT after a custom todo keyword removed",
+			input:    "#+TODO: TODO WAIT | DONE\n* WAIT COMMENT secret\nbody\n* WAIT [#A] COMMENT also secret\n* Kept",
+			expected: "#+TODO: TODO WAIT | DONE\n* Kept",
+		},
+		{
+			name:     "tasks removed",
+			input:    "#+OPTIONS: tasks:nil\n* TODO Task\nnote\n** Sub\n* Kept",
+			expected: "#+OPTIONS: tasks:nil\n* Kept",
+		},
+		{
+			name:     "only unfinished tasks",
+			input:    "#+OPTIONS: tasks:todo\n* TODO Open\n* DONE Closed\n** Sub\n* Kept",
+			expected: "#+OPTIONS: tasks:todo\n* TODO Open\n* Kept",
+		},
+		{
+			name:     "only done tasks",
+			input:    "#+TODO: TODO WAIT | DONE\n#+OPTIONS: tasks:\"done\"\n* WAIT Open\n* DONE Closed",
+			expected: "#+TODO: TODO WAIT | DONE\n#+OPTIONS: tasks:\"done\"\n* DONE Closed",
+		},
+		{
+			name:     "listed task keywords",
+			input:    "#+TODO: TODO WAIT | DONE\n#+OPTIONS: tasks:(\"TODO\" \"WAIT\")\n* TODO A\n* WAIT B\n* DONE C",
+			expected: "#+TODO: TODO WAIT | DONE\n#+OPTIONS: tasks:(\"TODO\" \"WAIT\")\n* TODO A\n* WAIT B",
+		},
+		{
+			name:     "planning lines and logbook removed by default",
+			input:    "* DONE Task\nCLOSED: [2024-01-02 Tue]\n:LOGBOOK:\n- State \"DONE\"\n:END:\n:NOTES:\nnote\n:END:\nbody",
+			expected: "* DONE Task\n:NOTES:\nnote\n:END:\nbody",
+		},
+		{
+			name:     "planning lines kept and drawers removed",
+			input:    "#+OPTIONS: p:t d:nil\n* Task\nSCHEDULED: <2024-01-02 Tue>\n:PROPERTIES:\n:ID: x\n:END:\n:NOTES:\nnote\n:END:\nbody",
+			expected: "#+OPTIONS: p:t d:nil\n* Task\nSCHEDULED: <2024-01-02 Tue>\n:PROPERTIES:\n:ID: x\n:END:\nbody",
+		},
+		{
+			name:     "listed drawers kept",
+			input:    "#+OPTIONS: d:(\"NOTES\")\n:NOTES:\nnote\n:END:\n:HIDDEN:\nsecret\n:END:",
+			expected: "#+OPTIONS: d:(\"NOTES\")\n:NOTES:\nnote\n:END:",
+		},
+		{
+			name:     "headlines in blocks untouched",
+			input:    "#+begin_example\n* COMMENT literal :noexport:\n#+end_example\n* Real",
+			expected: "#+begin_example\n* COMMENT literal :noexport:\n#+end_example\n* Real",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := applyOrgExportOptions(pruneOrgExport(tt.input))
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+)
+
+// orgEyecatchProperty sets the eyecatch of a subtree post; whole-file posts
+// use #+EYECATCH:.
+const orgEyecatchProperty = "EXPORT_HATENA_EYECATCH"
+
+// resolveEyecatch returns the URL of the eyecatch image: value itself for
+// an http(s) URL, or else the URL upload returns for the local file, which
+// is relative to baseDir. value may be written as an org link.
+func resolveEyecatch(value, baseDir string, upload func(path string) (string, error)) (string, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[[") && strings.HasSuffix(value, "]]") {
+		value = strings.TrimSuffix(strings.TrimPrefix(value, "[["), "]]")
+		value, _, _ = strings.Cut(value, "][")
+	}
+	if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+		return value, nil
+	}
+
+	path := strings.TrimPrefix(value, "file:")
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	if !fileExists(path) {
+		return "", fmt.Errorf("eyecatch image not found: %s", path)
+	}
+	imageURL, err := upload(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload eyecatch image: %v", err)
+	}
+	return imageURL, nil
+}
+
+// insertEyecatch puts the image at the top of content, hidden, so that
+// Hatena, which takes the first image of an entry as its eyecatch, picks it
+// without it showing in the article. It does nothing when eyecatch is
+// empty.
+func insertEyecatch(content, eyecatch, baseDir string, client *HatenaClient, debug bool) (string, error) {
+	if eyecatch == "" {
+		return content, nil
+	}
+	imageURL, err := resolveEyecatch(eyecatch, baseDir, func(path string) (string, error) {
+		return client.UploadImage(path, debug)
+	})
+	if err != nil {
+		return "", err
+	}
+	hidden := `<p class="eyecatch" style="display: none;"><img src="` + html.EscapeString(imageURL) + `" alt="" /></p>`
+	return hidden + "\n\n" + content, nil
+}
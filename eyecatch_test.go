@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveEyecatch(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "top.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var uploaded []string
+	upload := func(path string) (string, error) {
+		uploaded = append(uploaded, path)
+		return "https://cdn-ak.f.st-hatena.com/images/fotolife/t/testuser/20240102/20240102103000.png", nil
+	}
+
+	tests := []struct {
+		value    string
+		expected string
+		upload   bool
+		wantErr  bool
+	}{
+		{"https://example.com/top.png", "https://example.com/top.png", false, false},
+		{"[[https://example.com/top.png]]", "https://example.com/top.png", false, false},
+		{"top.png", "https://cdn-ak.f.st-hatena.com/images/fotolife/t/testuser/20240102/20240102103000.png", true, false},
+		{"[[file:top.png][Top]]", "https://cdn-ak.f.st-hatena.com/images/fotolife/t/testuser/20240102/20240102103000.png", true, false},
+		{"missing.png", "", false, true},
+	}
+
+	for _, test := range tests {
+		uploaded = nil
+		imageURL, err := resolveEyecatch(test.value, tempDir, upload)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("resolveEyecatch(%q) should fail", test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveEyecatch(%q) failed: %v", test.value, err)
+			continue
+		}
+		if imageURL != test.expected {
+			t.Errorf("resolveEyecatch(%q) = %q, expected %q", test.value, imageURL, test.expected)
+		}
+		if test.upload && (len(uploaded) != 1 || uploaded[0] != filepath.Join(tempDir, "top.png")) {
+			t.Errorf("resolveEyecatch(%q) should upload the local file, uploaded %v", test.value, uploaded)
+		}
+		if !test.upload && len(uploaded) != 0 {
+			t.Errorf("resolveEyecatch(%q) should not upload, uploaded %v", test.value, uploaded)
+		}
+	}
+}
+
+func TestResolveEyecatchUploadError(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "top.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := resolveEyecatch("top.png", tempDir, func(string) (string, error) {
+		return "", fmt.Errorf("status 403")
+	})
+	if err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("Expected the upload error, got %v", err)
+	}
+}
+
+func TestInsertEyecatch(t *testing.T) {
+	content, err := insertEyecatch("Body", "", "", nil, false)
+	if err != nil || content != "Body" {
+		t.Errorf("Content without an eyecatch should be unchanged, got %q, %v", content, err)
+	}
+
+	content, err = insertEyecatch("Body", "https://example.com/a.png?x=1&y=2", "", nil, false)
+	if err != nil {
+		t.Fatalf("insertEyecatch failed: %v", err)
+	}
+	expected := `<p class="eyecatch" style="display: none;"><img src="https://example.com/a.png?x=1&amp;y=2" alt="" /></p>` + "\n\nBody"
+	if content != expected {
+		t.Errorf("Expected:\n%q\nGot:\n%q", expected, content)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	orgAffiliatedRe = regexp.MustCompile(`(?i)^\s*#\+(CAPTION|NAME|ATTR_[A-Z0-9_-]+|HEADER|PLOT):[ \t]*(.*?)[ \t]*$`)
+	orgImageLinkRe  = regexp.MustCompile(`(?i)^\s*\[\[((?:file:)?[^\]]+\.(?:png|jpe?g|gif|svg|webp|bmp))\](?:\[([^\]]*)\])?\][ \t]*$`)
+	orgTableLineRe  = regexp.MustCompile(`^\s*\|`)
+)
+
+// orgAffiliated holds the affiliated keywords attached to an element.
+type orgAffiliated struct {
+	Caption string
+	Name    string
+	// Attrs are the :key value pairs of #+ATTR_HTML:.
+	Attrs map[string]string
+}
+
+// processOrgFigures renders images and tables that have a #+CAPTION: or
+// #+ATTR_HTML: as HTML export blocks: images become <figure> elements with
+// a <figcaption>, carrying alt text, width and height, and tables get a
+// caption line above them. When number is set, captions are prefixed with
+// "図 N" or "表 N".
+func processOrgFigures(orgContent string, number bool) string {
+	lines := strings.Split(orgContent, "\n")
+	var result []string
+	figures, tables := 0, 0
+	inBlock := false
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if isOrgBlockBoundary(line, &inBlock) || inBlock || !orgAffiliatedRe.MatchString(line) {
+			result = append(result, line)
+			continue
+		}
+
+		start := i
+		for i < len(lines) && orgAffiliatedRe.MatchString(lines[i]) {
+			i++
+		}
+		affiliated := parseOrgAffiliated(lines[start:i])
+
+		if i < len(lines) {
+			if m := orgImageLinkRe.FindStringSubmatch(lines[i]); m != nil && (affiliated.Caption != "" || len(affiliated.Attrs) > 0) {
+				caption := affiliated.Caption
+				if caption != "" && number {
+					figures++
+					caption = fmt.Sprintf("図 %d: %s", figures, caption)
+				}
+				indent := lines[i][:len(lines[i])-len(strings.TrimLeft(lines[i], " \t"))]
+				result = append(result,
+					indent+"#+begin_export html",
+					indent+renderOrgFigure(strings.TrimPrefix(m[1], "file:"), m[2], caption, affiliated),
+					indent+"#+end_export")
+				continue
+			}
+			if orgTableLineRe.MatchString(lines[i]) && affiliated.Caption != "" {
+				caption := affiliated.Caption
+				if number {
+					tables++
+					caption = fmt.Sprintf("表 %d: %s", tables, caption)
+				}
+				result = append(result,
+					"#+begin_export html",
+					`<div class="table-caption">`+html.EscapeString(caption)+`</div>`,
+					"#+end_export")
+				i--
+				continue
+			}
+		}
+
+		// Not an element we render; keep the keywords for pandoc
+		result = append(result, lines[start:i]...)
+		i--
+	}
+
+	return strings.Join(result, "\n")
+}
+
+func parseOrgAffiliated(lines []string) orgAffiliated {
+	affiliated := orgAffiliated{Attrs: map[string]string{}}
+	for _, line := range lines {
+		m := orgAffiliatedRe.FindStringSubmatch(line)
+		switch strings.ToUpper(m[1]) {
+		case "CAPTION":
+			// Multiple #+CAPTION: lines are concatenated, as in org
+			if affiliated.Caption != "" {
+				affiliated.Caption += " "
+			}
+			affiliated.Caption += m[2]
+		case "NAME":
+			affiliated.Name = m[2]
+		case "ATTR_HTML":
+			for key, value := range parseOrgAttributes(m[2]) {
+				affiliated.Attrs[key] = value
+			}
+		}
+	}
+	return affiliated
+}
+
+// parseOrgAttributes parses a ":key value :key2 value2" attribute list.
+// Values may span several words.
+func parseOrgAttributes(value string) map[string]string {
+	attrs := map[string]string{}
+	args := splitOrgArguments(value)
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], ":") {
+			continue
+		}
+		key := strings.ToLower(args[i][1:])
+		var values []string
+		for i+1 < len(args) && !strings.HasPrefix(args[i+1], ":") {
+			values = append(values, args[i+1])
+			i++
+		}
+		attrs[key] = strings.Join(values, " ")
+	}
+	return attrs
+}
+
+// renderOrgFigure renders an image as Hatena's figure markup. Without a
+// caption only the <img> is written.
+func renderOrgFigure(src, description, caption string, affiliated orgAffiliated) string {
+	alt, ok := affiliated.Attrs["alt"]
+	if !ok {
+		alt = description
+	}
+	if alt == "" {
+		alt = affiliated.Caption
+	}
+
+	var img strings.Builder
+	img.WriteString(`<img src="` + html.EscapeString(src) + `" alt="` + html.EscapeString(alt) + `"`)
+	for _, key := range []string{"width", "height", "class", "title"} {
+		if value, ok := affiliated.Attrs[key]; ok && value != "" {
+			img.WriteString(" " + key + `="` + html.EscapeString(value) + `"`)
+		}
+	}
+	img.WriteString(">")
+
+	if caption == "" {
+		return "<p>" + img.String() + "</p>"
+	}
+	return `<figure class="figure-image figure-image-fotolife" title="` + html.EscapeString(affiliated.Caption) + `">` +
+		img.String() + "<figcaption>" + html.EscapeString(caption) + "</figcaption></figure>"
+}
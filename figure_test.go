@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOrgAttributes(t *testing.T) {
+	attrs := parseOrgAttributes(`:width 400 :alt システム構成図 の概要 :title "a b"`)
+	expected := map[string]string{"width": "400", "alt": "システム構成図 の概要", "title": "a b"}
+	if !reflect.DeepEqual(attrs, expected) {
+		t.Errorf("Expected %v, got %v", expected, attrs)
+	}
+}
+
+func TestProcessOrgFigures(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		number   bool
+		expected string
+	}{
+		{
+			name:     "captioned image",
+			input:    "#+CAPTION: 構成図\n#+ATTR_HTML: :width 400 :alt システム構成\n[[file:images/arch.png]]",
+			expected: "#+begin_export html\n<figure class=\"figure-image figure-image-fotolife\" title=\"構成図\"><img src=\"images/arch.png\" alt=\"システム構成\" width=\"400\"><figcaption>構成図</figcaption></figure>\n#+end_export",
+		},
+		{
+			name:     "caption is the default alt",
+			input:    "#+CAPTION: A & B\n[[https://example.com/a.jpg]]",
+			expected: "#+begin_export html\n<figure class=\"figure-image figure-image-fotolife\" title=\"A &amp; B\"><img src=\"https://example.com/a.jpg\" alt=\"A &amp; B\"><figcaption>A &amp; B</figcaption></figure>\n#+end_export",
+		},
+		{
+			name:     "attributes without caption",
+			input:    "#+ATTR_HTML: :height 200\n[[./a.png][説明]]",
+			expected: "#+begin_export html\n<p><img src=\"./a.png\" alt=\"説明\" height=\"200\"></p>\n#+end_export",
+		},
+		{
+			name:     "numbered figures and tables",
+			input:    "#+CAPTION: 一つ目\n[[a.png]]\n\n#+CAPTION: 結果\n| a | b |\n\n#+CAPTION: 二つ目\n[[b.png]]",
+			number:   true,
+			expected: "#+begin_export html\n<figure class=\"figure-image figure-image-fotolife\" title=\"一つ目\"><img src=\"a.png\" alt=\"一つ目\"><figcaption>図 1: 一つ目</figcaption></figure>\n#+end_export\n\n#+begin_export html\n<div class=\"table-caption\">表 1: 結果</div>\n#+end_export\n| a | b |\n\n#+begin_export html\n<figure class=\"figure-image figure-image-fotolife\" title=\"二つ目\"><img src=\"b.png\" alt=\"二つ目\"><figcaption>図 2: 二つ目</figcaption></figure>\n#+end_export",
+		},
+		{
+			name:     "other elements keep their keywords",
+			input:    "#+NAME: data\n#+begin_example\n[[a.png]]\n#+end_example\n#+CAPTION: text\nparagraph",
+			expected: "#+NAME: data\n#+begin_example\n[[a.png]]\n#+end_example\n#+CAPTION: text\nparagraph",
+		},
+		{
+			name:     "image without keywords untouched",
+			input:    "[[file:a.png]]",
+			expected: "[[file:a.png]]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := processOrgFigures(tt.input, tt.number)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestConvertOrgFigureToMarkdown(t *testing.T) {
+	if !isPandocAvailable() {
+		t.Skip("pandoc not available")
+	}
+	result, err := convertOrgContent("#+CAPTION: 構成図\n[[file:arch.png]]\n", ConvertOptions{})
+	if err != nil {
+		t.Fatalf("convertOrgContent failed: %v", err)
+	}
+	if !strings.Contains(result, "<figcaption>構成図</figcaption>") {
+		t.Errorf("Expected figure markup in output, got %q", result)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	FootnotesMarkdown = "markdown"
+	FootnotesHatena   = "hatena"
+)
+
+var orgFootnoteDefRe = regexp.MustCompile(`^\[fn:([^\]\s:]+)\][ \t]*(.*)$`)
+
+func validateFootnotes(footnotes string) error {
+	switch footnotes {
+	case "", FootnotesMarkdown, FootnotesHatena:
+		return nil
+	}
+	return fmt.Errorf("unsupported footnote style: %s (expected %q or %q)", footnotes, FootnotesMarkdown, FootnotesHatena)
+}
+
+// convertOrgFootnotes replaces footnote references ([fn:1], [fn:name]) and
+// inline footnotes ([fn::text], [fn:name:text]) with Hatena's ((text))
+// notation, removing the definitions. The lines of a definition are joined
+// and its paragraphs separated by line breaks, since Hatena footnotes are
+// inline. A "Footnotes" heading left empty is removed as well.
+func convertOrgFootnotes(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	definitions := map[string]string{}
+	var body []string
+	inBlock := false
+
+	for i := 0; i < len(lines); i++ {
+		if isOrgBlockBoundary(lines[i], &inBlock) || inBlock {
+			body = append(body, lines[i])
+			continue
+		}
+		m := orgFootnoteDefRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			body = append(body, lines[i])
+			continue
+		}
+
+		var paragraphs []string
+		current := strings.TrimSpace(m[2])
+		blanks := 0
+		for i+1 < len(lines) {
+			next := lines[i+1]
+			if orgFootnoteDefRe.MatchString(next) || isOrgHeading(next) {
+				break
+			}
+			if strings.TrimSpace(next) == "" {
+				blanks++
+				if blanks == 2 {
+					// Two blank lines end a definition
+					break
+				}
+				i++
+				continue
+			}
+			if blanks > 0 && current != "" {
+				paragraphs = append(paragraphs, current)
+				current = ""
+			}
+			blanks = 0
+			if current == "" {
+				current = strings.TrimSpace(next)
+			} else {
+				current = joinLines(current, strings.TrimSpace(next))
+			}
+			i++
+		}
+		if current != "" {
+			paragraphs = append(paragraphs, current)
+		}
+		definitions[m[1]] = strings.Join(paragraphs, "@@html:<br>@@")
+	}
+
+	body = removeEmptyFootnoteHeadings(body)
+
+	inBlock = false
+	for i, line := range body {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		body[i] = replaceOrgFootnoteReferences(line, definitions)
+	}
+	return strings.Join(body, "\n")
+}
+
+// replaceOrgFootnoteReferences rewrites the footnotes in a line. Inline
+// footnotes may contain brackets, e.g. links, so they are matched by
+// counting brackets.
+func replaceOrgFootnoteReferences(line string, definitions map[string]string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		if !strings.HasPrefix(line[i:], "[fn:") {
+			b.WriteByte(line[i])
+			i++
+			continue
+		}
+
+		end, depth := -1, 0
+		for j := i; j < len(line); j++ {
+			switch line[j] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+			}
+			if depth == 0 {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			b.WriteString(line[i:])
+			break
+		}
+
+		inner := line[i+len("[fn:") : end]
+		name, text, inline := strings.Cut(inner, ":")
+		if !inline {
+			var ok bool
+			text, ok = definitions[name]
+			if !ok {
+				warnf("footnote [fn:%s] is not defined", name)
+				b.WriteString(line[i : end+1])
+				i = end + 1
+				continue
+			}
+		} else if name != "" {
+			// A named inline footnote may be referenced again
+			definitions[name] = strings.TrimSpace(text)
+		}
+		text = strings.TrimSpace(text)
+		if strings.Contains(text, "))") {
+			warnf("footnote %q contains \"))\", which ends a Hatena footnote early", text)
+		}
+		b.WriteString("((" + text + "))")
+		i = end + 1
+	}
+	return b.String()
+}
+
+// removeEmptyFootnoteHeadings drops "Footnotes" headings whose section held
+// nothing but the footnote definitions.
+func removeEmptyFootnoteHeadings(lines []string) []string {
+	var result []string
+	inBlock := false
+	for i := 0; i < len(lines); i++ {
+		if isOrgBlockBoundary(lines[i], &inBlock) || inBlock {
+			result = append(result, lines[i])
+			continue
+		}
+		level, _, title, _ := parseOrgHeadline(lines[i])
+		if level == 0 || (title != "Footnotes" && title != "脚注") {
+			result = append(result, lines[i])
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+			end++
+		}
+		if end < len(lines) {
+			if nextLevel, _, _, _ := parseOrgHeadline(lines[end]); nextLevel == 0 || nextLevel > level {
+				result = append(result, lines[i])
+				continue
+			}
+		}
+		i = end - 1
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestConvertOrgFootnotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "numbered footnotes",
+			input:    "Text[fn:1] and more[fn:2].\n\n* Footnotes\n\n[fn:1] First note.\n[fn:2] Second\nnote with [[https://example.com][a link]].",
+			expected: "Text((First note.)) and more((Second note with [[https://example.com][a link]].)).\n",
+		},
+		{
+			name:     "inline and named footnotes",
+			input:    "A[fn::inline =code=] B[fn:x:named [[https://a.example][link]]] C[fn:x]",
+			expected: "A((inline =code=)) B((named [[https://a.example][link]])) C((named [[https://a.example][link]]))",
+		},
+		{
+			name:     "multiple paragraphs and CJK lines",
+			input:    "本文[fn:note]\n\n[fn:note] 一段落目の\n続きです。\n\n二段落目です。\n\n\n後の段落",
+			expected: "本文((一段落目の続きです。@@html:<br>@@二段落目です。))\n\n\n後の段落",
+		},
+		{
+			name:     "footnotes heading with other content kept",
+			input:    "a[fn:1]\n* 脚注\nメモ\n[fn:1] note\n* Next",
+			expected: "a((note))\n* 脚注\nメモ\n* Next",
+		},
+		{
+			name:     "footnotes heading with subheadings kept",
+			input:    "a[fn:1]\n* Footnotes\n** Sub\n[fn:1] note",
+			expected: "a((note))\n* Footnotes\n** Sub",
+		},
+		{
+			name:     "undefined footnote and blocks untouched",
+			input:    "a[fn:missing]\n#+begin_src org\nb[fn:1]\n[fn:1] x\n#+end_src",
+			expected: "a[fn:missing]\n#+begin_src org\nb[fn:1]\n[fn:1] x\n#+end_src",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := convertOrgFootnotes(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidateFootnotes(t *testing.T) {
+	for _, style := range []string{"", FootnotesMarkdown, FootnotesHatena} {
+		if err := validateFootnotes(style); err != nil {
+			t.Errorf("Footnote style %q should be valid: %v", style, err)
+		}
+	}
+	if err := validateFootnotes("latex"); err == nil {
+		t.Error("Unknown footnote style should return error")
+	}
+}
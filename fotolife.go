@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fotolifeFolder is the Fotolife folder Hatena Blog keeps its images in.
+const fotolifeFolder = "Hatena Blog"
+
+// FotolifeEntry is the part of a Fotolife API response we use.
+type FotolifeEntry struct {
+	XMLName  xml.Name `xml:"entry"`
+	ImageURL string   `xml:"imageurl"`
+	Syntax   string   `xml:"syntax"`
+}
+
+func (c *HatenaClient) createImageXML(name, contentType string, data []byte) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<entry xmlns="http://purl.org/atom/ns#">
+  <title>%s</title>
+  <content mode="base64" type="%s">%s</content>
+  <dc:subject xmlns:dc="http://purl.org/dc/elements/1.1/">%s</dc:subject>
+</entry>`, html.EscapeString(name), html.EscapeString(contentType), base64.StdEncoding.EncodeToString(data), html.EscapeString(fotolifeFolder))
+}
+
+// UploadImage uploads a local image to Fotolife, authenticated with the
+// same WSSE header as the blog API, and returns the URL of the image.
+func (c *HatenaClient) UploadImage(imagePath string, debug bool) (string, error) {
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(imagePath)))
+	if !strings.HasPrefix(contentType, "image/") {
+		return "", fmt.Errorf("not an image file: %s", imagePath)
+	}
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %v", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(imagePath), filepath.Ext(imagePath))
+	imageXML := c.createImageXML(name, contentType, data)
+	if debug {
+		fmt.Printf("Uploading %s (%s, %d bytes) to Fotolife\n", imagePath, contentType, len(data))
+	}
+	req, err := http.NewRequest("POST", c.FotolifeURL, bytes.NewBufferString(imageXML))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("X-WSSE", c.createWSSEHeader())
+
+	client := &http.Client{
+		Timeout: 60 * time.Second,
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("upload request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("Fotolife upload failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var entry FotolifeEntry
+	if err := xml.Unmarshal(body, &entry); err != nil {
+		return "", fmt.Errorf("failed to parse response XML: %v", err)
+	}
+	if entry.ImageURL == "" {
+		return "", fmt.Errorf("image URL not found in Fotolife response")
+	}
+	return strings.TrimSpace(entry.ImageURL), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadImage(t *testing.T) {
+	image := []byte("\x89PNG fake image")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("X-WSSE") == "" {
+			t.Errorf("Unexpected request %s with X-WSSE %q", r.Method, r.Header.Get("X-WSSE"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		for _, expected := range []string{
+			"<title>top</title>",
+			`<content mode="base64" type="image/png">` + base64.StdEncoding.EncodeToString(image) + "</content>",
+			">Hatena Blog</dc:subject>",
+		} {
+			if !strings.Contains(string(body), expected) {
+				t.Errorf("Request should contain %q, got:\n%s", expected, body)
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<entry xmlns="http://purl.org/atom/ns#" xmlns:hatena="http://www.hatena.ne.jp/info/xmlns#">
+  <title>top</title>
+  <hatena:imageurl>https://cdn-ak.f.st-hatena.com/images/fotolife/t/testuser/20240102/20240102103000.png</hatena:imageurl>
+  <hatena:syntax>f:id:testuser:20240102103000p:image</hatena:syntax>
+</entry>`))
+	}))
+	defer server.Close()
+
+	imagePath := filepath.Join(t.TempDir(), "top.png")
+	if err := os.WriteFile(imagePath, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewHatenaClient("testuser", "testapi", "testblog.example.com")
+	client.FotolifeURL = server.URL
+
+	imageURL, err := client.UploadImage(imagePath, false)
+	if err != nil {
+		t.Fatalf("UploadImage failed: %v", err)
+	}
+	if imageURL != "https://cdn-ak.f.st-hatena.com/images/fotolife/t/testuser/20240102/20240102103000.png" {
+		t.Errorf("Unexpected image URL %q", imageURL)
+	}
+}
+
+func TestUploadImageRejectsNonImages(t *testing.T) {
+	textPath := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(textPath, []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := NewHatenaClient("testuser", "testapi", "testblog.example.com")
+	if _, err := client.UploadImage(textPath, false); err == nil {
+		t.Error("Uploading a non-image file should fail")
+	}
+}
@@ -19,13 +19,35 @@ type HatenaClient struct {
 	APIKey     string
 	BlogDomain string
 	BaseURL    string
+	// FotolifeURL is the endpoint images are uploaded to.
+	FotolifeURL string
 }
 
 type BlogEntry struct {
-	Title      string
-	Content    string
-	Categories []string
-	IsDraft    bool
+	Title       string
+	Content     string
+	ContentType string
+	Categories  []string
+	IsDraft     bool
+	// Updated is the entry date; the current time is used when zero.
+	Updated time.Time
+	// CustomURL is the custom path of the entry URL, if any.
+	CustomURL string
+	// Summary is the plain-text description used for social previews.
+	Summary string
+	// Author is the Hatena ID of the member the entry is attributed to; the
+	// authenticated user when empty.
+	Author string
+}
+
+// PostedEntry holds the URLs of an entry returned by the API.
+type PostedEntry struct {
+	// EditPageURL is the entry's page in the blog editor.
+	EditPageURL string
+	// EditURL is the AtomPub member URI of the entry.
+	EditURL string
+	// URL is the public URL of the entry.
+	URL string
 }
 
 type AtomEntry struct {
@@ -39,10 +61,11 @@ type AtomEntry struct {
 
 func NewHatenaClient(hatenaID, apiKey, blogDomain string) *HatenaClient {
 	return &HatenaClient{
-		HatenaID:   hatenaID,
-		APIKey:     apiKey,
-		BlogDomain: blogDomain,
-		BaseURL:    fmt.Sprintf("https://blog.hatena.ne.jp/%s/%s/atom", hatenaID, blogDomain),
+		HatenaID:    hatenaID,
+		APIKey:      apiKey,
+		BlogDomain:  blogDomain,
+		BaseURL:     fmt.Sprintf("https://blog.hatena.ne.jp/%s/%s/atom", hatenaID, blogDomain),
+		FotolifeURL: "https://f.hatena.ne.jp/atom/post",
 	}
 }
 
@@ -71,12 +94,28 @@ func (c *HatenaClient) createEntryXML(entry BlogEntry) string {
 		draftStatus = "yes"
 	}
 
+	contentType := entry.ContentType
+	if contentType == "" {
+		contentType = "text/x-markdown"
+	}
+
+	updated := entry.Updated
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	author := entry.Author
+	if author == "" {
+		author = c.HatenaID
+	}
+
 	xml := `<?xml version="1.0" encoding="utf-8"?>
 <entry xmlns="http://www.w3.org/2005/Atom"
-       xmlns:app="http://www.w3.org/2007/app">
+       xmlns:app="http://www.w3.org/2007/app"
+       xmlns:hatenablog="http://www.hatena.ne.jp/info/xmlns#hatenablog">
   <title>%s</title>
   <author><name>%s</name></author>
-  <content type="text/x-markdown">%s</content>
+  <content type="%s">%s</content>
   <updated>%s</updated>`
 
 	for _, category := range entry.Categories {
@@ -86,16 +125,26 @@ func (c *HatenaClient) createEntryXML(entry BlogEntry) string {
 		}
 	}
 
+	if entry.Summary != "" {
+		xml += fmt.Sprintf(`
+  <summary type="text">%s</summary>`, html.EscapeString(entry.Summary))
+	}
+
+	if entry.CustomURL != "" {
+		xml += fmt.Sprintf(`
+  <hatenablog:custom-url>%s</hatenablog:custom-url>`, html.EscapeString(entry.CustomURL))
+	}
+
 	xml += fmt.Sprintf(`
   <app:control>
     <app:draft>%s</app:draft>
   </app:control>
 </entry>`, draftStatus)
 
-	return fmt.Sprintf(xml, html.EscapeString(entry.Title), html.EscapeString(c.HatenaID), html.EscapeString(entry.Content), time.Now().Format(time.RFC3339))
+	return fmt.Sprintf(xml, html.EscapeString(entry.Title), html.EscapeString(author), html.EscapeString(contentType), html.EscapeString(entry.Content), updated.Format(time.RFC3339))
 }
 
-func (c *HatenaClient) PostEntry(entry BlogEntry, debug bool) (string, error) {
+func (c *HatenaClient) PostEntry(entry BlogEntry, debug bool) (*PostedEntry, error) {
 	entryXML := c.createEntryXML(entry)
 	if debug {
 		fmt.Println("Generated XML:")
@@ -103,7 +152,7 @@ func (c *HatenaClient) PostEntry(entry BlogEntry, debug bool) (string, error) {
 	}
 	req, err := http.NewRequest("POST", c.BaseURL+"/entry", bytes.NewBufferString(entryXML))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/xml")
@@ -115,39 +164,41 @@ func (c *HatenaClient) PostEntry(entry BlogEntry, debug bool) (string, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %v", err)
+		return nil, fmt.Errorf("request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %v", err)
+		return nil, fmt.Errorf("failed to read response body: %v", err)
 	}
 
 	var atomEntry AtomEntry
 	if err := xml.Unmarshal(body, &atomEntry); err != nil {
-		return "", fmt.Errorf("failed to parse response XML: %v", err)
+		return nil, fmt.Errorf("failed to parse response XML: %v", err)
 	}
 
-	var editURL string
+	var editURL, entryURL string
 	for _, link := range atomEntry.Links {
-		if link.Rel == "edit" {
+		switch link.Rel {
+		case "edit":
 			editURL = link.Href
-			break
+		case "alternate":
+			entryURL = link.Href
 		}
 	}
 
 	if editURL == "" {
-		return "", fmt.Errorf("edit link not found in API response")
+		return nil, fmt.Errorf("edit link not found in API response")
 	}
 
 	editPageURL := fmt.Sprintf("https://blog.hatena.ne.jp/%s/%s/edit?entry=%s", c.HatenaID, c.BlogDomain, extractEntryIDFromURL(editURL))
-	return editPageURL, nil
+	return &PostedEntry{EditPageURL: editPageURL, EditURL: editURL, URL: entryURL}, nil
 }
 
 func extractTitleFromMarkdown(content string) string {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -110,6 +112,68 @@ func TestCreateEntryXMLNoDraft(t *testing.T) {
 	}
 }
 
+func TestCreateEntryXMLHTMLContentType(t *testing.T) {
+	client := NewHatenaClient("testuser", "testapi", "testblog.example.com")
+	entry := BlogEntry{
+		Title:       "Test Title",
+		Content:     "<p>Test content</p>",
+		ContentType: "text/html",
+	}
+
+	xml := client.createEntryXML(entry)
+
+	if !strings.Contains(xml, "<content type=\"text/html\">&lt;p&gt;Test content&lt;/p&gt;</content>") {
+		t.Errorf("XML should contain HTML content type, got:\n%s", xml)
+	}
+}
+
+func TestCreateEntryXMLDateAndCustomURL(t *testing.T) {
+	client := NewHatenaClient("testuser", "testapi", "testblog.example.com")
+	entry := BlogEntry{
+		Title:     "Test Title",
+		Content:   "Test content",
+		Updated:   time.Date(2024, 1, 2, 10, 30, 0, 0, time.UTC),
+		CustomURL: "2024/first-post",
+	}
+
+	xml := client.createEntryXML(entry)
+
+	if !strings.Contains(xml, "<updated>2024-01-02T10:30:00Z</updated>") {
+		t.Errorf("XML should contain the entry date, got:\n%s", xml)
+	}
+	if !strings.Contains(xml, "<hatenablog:custom-url>2024/first-post</hatenablog:custom-url>") {
+		t.Errorf("XML should contain the custom URL, got:\n%s", xml)
+	}
+}
+
+func TestCreateEntryXMLSummary(t *testing.T) {
+	client := NewHatenaClient("testuser", "testapi", "testblog.example.com")
+
+	xml := client.createEntryXML(BlogEntry{Title: "Test Title", Content: "Test content"})
+	if strings.Contains(xml, "<summary") {
+		t.Errorf("XML should not contain an empty summary, got:\n%s", xml)
+	}
+
+	xml = client.createEntryXML(BlogEntry{Title: "Test Title", Content: "Test content", Summary: "Go & org"})
+	if !strings.Contains(xml, `<summary type="text">Go &amp; org</summary>`) {
+		t.Errorf("XML should contain the summary, got:\n%s", xml)
+	}
+}
+
+func TestCreateEntryXMLAuthor(t *testing.T) {
+	client := NewHatenaClient("owner", "testapi", "testblog.example.com")
+
+	xml := client.createEntryXML(BlogEntry{Title: "Test Title", Content: "Test content"})
+	if !strings.Contains(xml, "<author><name>owner</name></author>") {
+		t.Errorf("XML should be attributed to the authenticated user, got:\n%s", xml)
+	}
+
+	xml = client.createEntryXML(BlogEntry{Title: "Test Title", Content: "Test content", Author: "taro"})
+	if !strings.Contains(xml, "<author><name>taro</name></author>") {
+		t.Errorf("XML should be attributed to the member, got:\n%s", xml)
+	}
+}
+
 func TestExtractTitleFromMarkdown(t *testing.T) {
 	markdown := `# Test Title
 
@@ -212,3 +276,33 @@ func TestPostEntryDebugMode(t *testing.T) {
 		t.Error("Expected HTTP error since we're not making a real request")
 	}
 }
+
+func TestPostEntryReturnsURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/entry" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<entry xmlns="http://www.w3.org/2005/Atom">
+  <id>tag:blog.hatena.ne.jp,2013:blog-testuser-1-2</id>
+  <link rel="edit" href="https://blog.hatena.ne.jp/testuser/testblog.example.com/atom/entry/12345"/>
+  <link rel="alternate" type="text/html" href="https://testblog.example.com/entry/2024/01/02/first"/>
+</entry>`))
+	}))
+	defer server.Close()
+
+	client := NewHatenaClient("testuser", "testapi", "testblog.example.com")
+	client.BaseURL = server.URL
+
+	posted, err := client.PostEntry(BlogEntry{Title: "Test", Content: "Body"}, false)
+	if err != nil {
+		t.Fatalf("PostEntry failed: %v", err)
+	}
+	if posted.URL != "https://testblog.example.com/entry/2024/01/02/first" {
+		t.Errorf("Unexpected entry URL %q", posted.URL)
+	}
+	if posted.EditPageURL != "https://blog.hatena.ne.jp/testuser/testblog.example.com/edit?entry=12345" {
+		t.Errorf("Unexpected edit page URL %q", posted.EditPageURL)
+	}
+}
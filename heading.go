@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+)
+
+// maxHatenaHeadingLevel is the deepest heading HTML and markdown have.
+const maxHatenaHeadingLevel = 6
+
+// singleOrgTopHeading returns the only level-1 heading of a document
+// without a #+TITLE:, when nothing but keywords and comments come before it,
+// or nil. Such a heading is the title of the post.
+func singleOrgTopHeading(lines []string) *orgHeading {
+	var top *orgHeading
+	for _, h := range parseOrgHeadings(lines) {
+		if h.Level != 1 {
+			continue
+		}
+		if top != nil {
+			return nil
+		}
+		top = h
+	}
+	if top == nil {
+		return nil
+	}
+
+	for _, line := range lines[:top.start] {
+		trimmed := strings.TrimSpace(line)
+		if m := orgKeywordRe.FindStringSubmatch(line); m != nil {
+			if strings.EqualFold(m[1], "TITLE") && m[2] != "" {
+				return nil
+			}
+			continue
+		}
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") && !orgPropertyLineRe.MatchString(line) {
+			return nil
+		}
+	}
+	return top
+}
+
+// promoteSingleOrgHeading removes the heading that singleOrgTopHeading uses
+// as the title, with its planning line and property drawer, and promotes the
+// headings below it by one level.
+func promoteSingleOrgHeading(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	top := singleOrgTopHeading(lines)
+	if top == nil {
+		return orgContent
+	}
+
+	result := append([]string{}, lines[:top.start]...)
+	result = append(result, shiftOrgHeadings(lines[top.bodyStart:], -1)...)
+	return strings.Join(result, "\n")
+}
+
+// adjustOrgHeadingLevels shifts every heading by offset, so that org's
+// level 1 can become the h3 most Hatena themes style as sections, and warns
+// about headings that skip a level or end up deeper than h6.
+func adjustOrgHeadingLevels(orgContent string, offset int) string {
+	lines := strings.Split(orgContent, "\n")
+	prevLevel := 0
+	for _, h := range parseOrgHeadings(lines) {
+		if prevLevel > 0 && h.Level > prevLevel+1 {
+			warnf("heading %q skips from level %d to %d", h.Title, prevLevel, h.Level)
+		}
+		if h.Level+offset > maxHatenaHeadingLevel {
+			warnf("heading %q is deeper than h%d", h.Title, maxHatenaHeadingLevel)
+		}
+		prevLevel = h.Level
+	}
+
+	if offset == 0 {
+		return orgContent
+	}
+	return strings.Join(shiftOrgHeadings(lines, offset), "\n")
+}
@@ -0,0 +1,71 @@
+package main
+
+import "testing"
+
+func TestPromoteSingleOrgHeading(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "single heading promoted",
+			input:    "#+filetags: :a:\n\n* Title\n:PROPERTIES:\n:ID: x\n:END:\nintro\n** Section\n*** Sub",
+			expected: "#+filetags: :a:\n\nintro\n* Section\n** Sub",
+		},
+		{
+			name:     "explicit title",
+			input:    "#+TITLE: Post\n* Title\n** Section",
+			expected: "#+TITLE: Post\n* Title\n** Section",
+		},
+		{
+			name:     "text before heading",
+			input:    "intro\n* Title\n** Section",
+			expected: "intro\n* Title\n** Section",
+		},
+		{
+			name:     "several top-level headings",
+			input:    "* A\n** A1\n* B",
+			expected: "* A\n** A1\n* B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := promoteSingleOrgHeading(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestAdjustOrgHeadingLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		offset   int
+		expected string
+	}{
+		{
+			name:     "no offset",
+			input:    "* A\n** B",
+			expected: "* A\n** B",
+		},
+		{
+			name:     "offset two",
+			input:    "* A\ntext\n** B\n#+begin_src org\n* not a heading\n#+end_src",
+			offset:   2,
+			expected: "*** A\ntext\n**** B\n#+begin_src org\n* not a heading\n#+end_src",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := adjustOrgHeadingLevels(tt.input, tt.offset)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	orgIncludeRe   = regexp.MustCompile(`(?i)^\s*#\+INCLUDE:[ \t]*(.*?)[ \t]*$`)
+	orgSetupFileRe = regexp.MustCompile(`(?i)^\s*#\+SETUPFILE:[ \t]*(.*?)[ \t]*$`)
+	orgNameRe      = regexp.MustCompile(`(?i)^\s*#\+NAME:[ \t]*(.*?)[ \t]*$`)
+)
+
+// orgInclude is a parsed #+INCLUDE: directive.
+type orgInclude struct {
+	File string
+	// Target is the part after "::" in the file name: "*Heading",
+	// "#custom-id" or the #+NAME of an element.
+	Target string
+	// BlockType is "src", "example", "export" or another block name to wrap
+	// the included text in, or empty to include it as org.
+	BlockType string
+	// BlockArgs are the words after the block type, e.g. the language.
+	BlockArgs string
+	Lines     string
+	MinLevel  int
+}
+
+// expandOrgIncludes resolves #+INCLUDE: and #+SETUPFILE: directives in
+// orgContent, with relative paths resolved against baseDir. Included org
+// files are expanded recursively; an include cycle is an error.
+func expandOrgIncludes(orgContent, baseDir string) (string, error) {
+	return expandOrgIncludesFrom(orgContent, baseDir, nil)
+}
+
+func expandOrgIncludesFrom(orgContent, baseDir string, stack []string) (string, error) {
+	lines := strings.Split(orgContent, "\n")
+	var result []string
+	inBlock := false
+
+	for _, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			result = append(result, line)
+			continue
+		}
+
+		if m := orgSetupFileRe.FindStringSubmatch(line); m != nil {
+			keywords, err := readOrgSetupFile(unquoteOrgArgument(m[1]), baseDir, stack)
+			if err != nil {
+				return "", err
+			}
+			result = append(result, keywords...)
+			continue
+		}
+
+		if m := orgIncludeRe.FindStringSubmatch(line); m != nil {
+			include, err := parseOrgInclude(m[1])
+			if err != nil {
+				return "", err
+			}
+			included, err := readOrgInclude(include, baseDir, stack)
+			if err != nil {
+				return "", err
+			}
+			result = append(result, included)
+			continue
+		}
+
+		result = append(result, line)
+	}
+
+	return strings.Join(result, "\n"), nil
+}
+
+// parseOrgInclude parses the value of an #+INCLUDE: keyword such as
+// "code.py" src python :lines "5-10".
+func parseOrgInclude(value string) (orgInclude, error) {
+	args := splitOrgArguments(value)
+	if len(args) == 0 {
+		return orgInclude{}, fmt.Errorf("#+INCLUDE: without a file name")
+	}
+
+	var include orgInclude
+	include.File = args[0]
+	if i := strings.Index(include.File, "::"); i >= 0 {
+		include.Target = include.File[i+2:]
+		include.File = include.File[:i]
+	}
+
+	rest := args[1:]
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], ":") {
+		include.BlockType = strings.ToLower(rest[0])
+		rest = rest[1:]
+		var blockArgs []string
+		for len(rest) > 0 && !strings.HasPrefix(rest[0], ":") {
+			blockArgs = append(blockArgs, rest[0])
+			rest = rest[1:]
+		}
+		include.BlockArgs = strings.Join(blockArgs, " ")
+	}
+
+	for i := 0; i+1 < len(rest); i += 2 {
+		switch strings.ToLower(rest[i]) {
+		case ":lines":
+			include.Lines = rest[i+1]
+		case ":minlevel":
+			level, err := strconv.Atoi(rest[i+1])
+			if err != nil || level < 1 {
+				return orgInclude{}, fmt.Errorf("invalid :minlevel in #+INCLUDE: %s", value)
+			}
+			include.MinLevel = level
+		}
+	}
+
+	return include, nil
+}
+
+// splitOrgArguments splits a keyword value on whitespace, keeping double
+// quoted strings together and removing their quotes.
+func splitOrgArguments(value string) []string {
+	var args []string
+	var current strings.Builder
+	inQuote, hasArg := false, false
+
+	for _, r := range value {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+func unquoteOrgArgument(value string) string {
+	args := splitOrgArguments(value)
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// resolveIncludePath returns the absolute path of file relative to baseDir
+// and checks it against the chain of files being expanded.
+func resolveIncludePath(file, baseDir string, stack []string) (string, error) {
+	if strings.Contains(file, "://") {
+		return "", fmt.Errorf("remote files are not supported: %s", file)
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(baseDir, file)
+	}
+	absPath, err := filepath.Abs(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	for _, p := range stack {
+		if p == absPath {
+			return "", fmt.Errorf("include cycle detected: %s", strings.Join(append(stack, absPath), " -> "))
+		}
+	}
+	return absPath, nil
+}
+
+func readOrgInclude(include orgInclude, baseDir string, stack []string) (string, error) {
+	absPath, err := resolveIncludePath(include.File, baseDir, stack)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read included file: %v", err)
+	}
+	content, err := decodeText(data, "")
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", include.File, err)
+	}
+
+	content = strings.TrimSuffix(content, "\n")
+	if include.Target != "" {
+		content, err = selectOrgIncludeTarget(content, include.Target)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", include.File, err)
+		}
+	}
+	if include.Lines != "" {
+		content, err = selectOrgIncludeLines(content, include.Lines)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", include.File, err)
+		}
+	}
+
+	if include.BlockType != "" {
+		return wrapOrgBlock(content, include.BlockType, include.BlockArgs), nil
+	}
+
+	// Included org text may itself include other files, relative to itself
+	content, err = expandOrgIncludesFrom(content, filepath.Dir(absPath), append(stack, absPath))
+	if err != nil {
+		return "", err
+	}
+	if include.MinLevel > 0 {
+		content = setOrgMinLevel(content, include.MinLevel)
+	}
+	return content, nil
+}
+
+// selectOrgIncludeTarget returns the subtree or named element that target
+// refers to.
+func selectOrgIncludeTarget(content, target string) (string, error) {
+	lines := strings.Split(content, "\n")
+
+	if strings.HasPrefix(target, "*") || strings.HasPrefix(target, "#") {
+		for _, h := range parseOrgHeadings(lines) {
+			if (strings.HasPrefix(target, "*") && h.Title == target[1:]) ||
+				(strings.HasPrefix(target, "#") && h.Properties["CUSTOM_ID"] == target[1:]) {
+				return strings.Join(lines[h.start:h.end], "\n"), nil
+			}
+		}
+		return "", fmt.Errorf("heading %q not found", target)
+	}
+
+	for i, line := range lines {
+		m := orgNameRe.FindStringSubmatch(line)
+		if m == nil || m[1] != target {
+			continue
+		}
+		// The named element is the following block, or paragraph up to the
+		// next blank line.
+		end := i + 1
+		for end < len(lines) && orgKeywordRe.MatchString(lines[end]) {
+			end++
+		}
+		if end < len(lines) && strings.HasPrefix(strings.ToLower(strings.TrimSpace(lines[end])), "#+begin_") {
+			inBlock := false
+			for ; end < len(lines); end++ {
+				isOrgBlockBoundary(lines[end], &inBlock)
+				if !inBlock {
+					return strings.Join(lines[i:end+1], "\n"), nil
+				}
+			}
+			return strings.Join(lines[i:], "\n"), nil
+		}
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			end++
+		}
+		return strings.Join(lines[i:end], "\n"), nil
+	}
+	return "", fmt.Errorf("element named %q not found", target)
+}
+
+// selectOrgIncludeLines applies a :lines range such as "5-10", "-10" or
+// "10-". As in org, the end line is excluded.
+func selectOrgIncludeLines(content, lineRange string) (string, error) {
+	lines := strings.Split(content, "\n")
+	parts := strings.SplitN(lineRange, "-", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid :lines range %q", lineRange)
+	}
+
+	start, end := 1, len(lines)+1
+	var err error
+	if parts[0] != "" {
+		if start, err = strconv.Atoi(parts[0]); err != nil || start < 1 {
+			return "", fmt.Errorf("invalid :lines range %q", lineRange)
+		}
+	}
+	if parts[1] != "" {
+		if end, err = strconv.Atoi(parts[1]); err != nil {
+			return "", fmt.Errorf("invalid :lines range %q", lineRange)
+		}
+	}
+
+	if start > len(lines) {
+		return "", nil
+	}
+	if end > len(lines)+1 {
+		end = len(lines) + 1
+	}
+	if end <= start {
+		return "", nil
+	}
+	return strings.Join(lines[start-1:end-1], "\n"), nil
+}
+
+// wrapOrgBlock puts content into a #+begin_<blockType> block, escaping lines
+// that org would otherwise read as headlines or keywords.
+func wrapOrgBlock(content, blockType, blockArgs string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "*") || strings.HasPrefix(trimmed, "#+") ||
+			strings.HasPrefix(trimmed, ",*") || strings.HasPrefix(trimmed, ",#+") {
+			lines[i] = line[:len(line)-len(trimmed)] + "," + trimmed
+		}
+	}
+
+	begin := "#+begin_" + blockType
+	if blockArgs != "" {
+		begin += " " + blockArgs
+	}
+	return begin + "\n" + strings.Join(lines, "\n") + "\n#+end_" + blockType
+}
+
+// setOrgMinLevel shifts headlines so that the shallowest one is at level.
+func setOrgMinLevel(content string, level int) string {
+	lines := strings.Split(content, "\n")
+	headings := parseOrgHeadings(lines)
+	if len(headings) == 0 {
+		return content
+	}
+
+	minLevel := headings[0].Level
+	for _, h := range headings {
+		if h.Level < minLevel {
+			minLevel = h.Level
+		}
+	}
+	return strings.Join(shiftOrgHeadings(lines, level-minLevel), "\n")
+}
+
+// readOrgSetupFile returns the keyword lines of a #+SETUPFILE:, including
+// those of setup files it refers to in turn.
+func readOrgSetupFile(file, baseDir string, stack []string) ([]string, error) {
+	absPath, err := resolveIncludePath(file, baseDir, stack)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read setup file: %v", err)
+	}
+	content, err := decodeText(data, "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	var keywords []string
+	inBlock := false
+	for _, line := range strings.Split(content, "\n") {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		if m := orgSetupFileRe.FindStringSubmatch(line); m != nil {
+			nested, err := readOrgSetupFile(unquoteOrgArgument(m[1]), filepath.Dir(absPath), append(stack, absPath))
+			if err != nil {
+				return nil, err
+			}
+			keywords = append(keywords, nested...)
+			continue
+		}
+		// Only settings are taken from a setup file, never content
+		if orgKeywordRe.MatchString(line) && !orgIncludeRe.MatchString(line) {
+			keywords = append(keywords, strings.TrimSpace(line))
+		}
+	}
+	return keywords, nil
+}
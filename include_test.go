@@ -0,0 +1,160 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create temp file: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestExpandOrgIncludes(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"bio.org":            "* About the author\nWritten by garaemon.\n",
+		"common/setup.org":   "#+MACRO: tool hatena-blog-org\n#+LINK: gh https://github.com/%s\n* Not a setting\ntext\n#+SETUPFILE: nested.org\n",
+		"common/nested.org":  "#+OPTIONS: toc:nil\n",
+		"code.py":            "import os\n\ndef main():\n    print(os.getcwd())\n\nmain()\n",
+		"notes.org":          "* Notes\n** First\nfirst text\n** Second\n:PROPERTIES:\n:CUSTOM_ID: second\n:END:\nsecond text\n",
+		"snippets.org":       "intro\n\n#+NAME: hello\n#+begin_src go\nfmt.Println(\"hello\")\n#+end_src\n\noutro\n",
+		"deep.org":           "#+INCLUDE: \"bio.org\" :minlevel 3\n",
+		"headline_src.org":   "* heading\n#+title: x\n",
+		"disclaimer/one.org": "#+INCLUDE: \"../bio.org\"\n",
+	})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "whole file",
+			input:    "before\n#+INCLUDE: \"bio.org\"\nafter",
+			expected: "before\n* About the author\nWritten by garaemon.\nafter",
+		},
+		{
+			name:     "minlevel",
+			input:    "#+INCLUDE: \"bio.org\" :minlevel 2",
+			expected: "** About the author\nWritten by garaemon.",
+		},
+		{
+			name:     "line range as src block",
+			input:    "#+INCLUDE: \"code.py\" src python :lines \"3-5\"",
+			expected: "#+begin_src python\ndef main():\n    print(os.getcwd())\n#+end_src",
+		},
+		{
+			name:     "org text in example block is escaped",
+			input:    "#+include: headline_src.org example",
+			expected: "#+begin_example\n,* heading\n,#+title: x\n#+end_example",
+		},
+		{
+			name:     "heading target",
+			input:    "#+INCLUDE: \"notes.org::*First\"",
+			expected: "** First\nfirst text",
+		},
+		{
+			name:     "custom id target with minlevel",
+			input:    "#+INCLUDE: \"notes.org::#second\" :minlevel 1",
+			expected: "* Second\n:PROPERTIES:\n:CUSTOM_ID: second\n:END:\nsecond text",
+		},
+		{
+			name:     "named src block",
+			input:    "#+INCLUDE: \"snippets.org::hello\"",
+			expected: "#+NAME: hello\n#+begin_src go\nfmt.Println(\"hello\")\n#+end_src",
+		},
+		{
+			name:     "nested include relative to included file",
+			input:    "#+INCLUDE: \"disclaimer/one.org\"",
+			expected: "* About the author\nWritten by garaemon.",
+		},
+		{
+			name:     "nested include keeps its own minlevel",
+			input:    "#+INCLUDE: \"deep.org\"",
+			expected: "*** About the author\nWritten by garaemon.",
+		},
+		{
+			name:     "setupfile keywords only",
+			input:    "#+SETUPFILE: common/setup.org\n* Post",
+			expected: "#+MACRO: tool hatena-blog-org\n#+LINK: gh https://github.com/%s\n#+OPTIONS: toc:nil\n* Post",
+		},
+		{
+			name:     "directives inside blocks untouched",
+			input:    "#+begin_example\n#+INCLUDE: \"missing.org\"\n#+end_example",
+			expected: "#+begin_example\n#+INCLUDE: \"missing.org\"\n#+end_example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := expandOrgIncludes(tt.input, dir)
+			if err != nil {
+				t.Fatalf("expandOrgIncludes failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestExpandOrgIncludesErrors(t *testing.T) {
+	dir := writeTestFiles(t, map[string]string{
+		"a.org":     "#+INCLUDE: \"b.org\"\n",
+		"b.org":     "#+INCLUDE: \"a.org\"\n",
+		"self.org":  "#+SETUPFILE: self.org\n",
+		"notes.org": "* Notes\n",
+	})
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "include cycle", input: "#+INCLUDE: \"a.org\"", want: "cycle"},
+		{name: "setupfile cycle", input: "#+SETUPFILE: self.org", want: "cycle"},
+		{name: "missing file", input: "#+INCLUDE: \"missing.org\"", want: "failed to read"},
+		{name: "missing heading", input: "#+INCLUDE: \"notes.org::*Other\"", want: "not found"},
+		{name: "remote setupfile", input: "#+SETUPFILE: https://example.com/setup.org", want: "remote"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := expandOrgIncludes(tt.input, dir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSelectOrgIncludeLines(t *testing.T) {
+	content := "1\n2\n3\n4\n5"
+	tests := map[string]string{
+		"2-4": "2\n3",
+		"-3":  "1\n2",
+		"4-":  "4\n5",
+		"9-":  "",
+	}
+	for lineRange, expected := range tests {
+		result, err := selectOrgIncludeLines(content, lineRange)
+		if err != nil {
+			t.Fatalf("selectOrgIncludeLines(%q) failed: %v", lineRange, err)
+		}
+		if result != expected {
+			t.Errorf("selectOrgIncludeLines(%q) = %q, expected %q", lineRange, result, expected)
+		}
+	}
+}
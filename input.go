@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	InputOrg      = "org"
+	InputMarkdown = "markdown"
+	InputAsciiDoc = "asciidoc"
+	InputRST      = "rst"
+)
+
+var (
+	asciiDocAttributeRe = regexp.MustCompile(`^:([\w-]+!?):[ \t]*(.*?)[ \t]*$`)
+	rstFieldRe          = regexp.MustCompile(`^:([^:\s][^:]*):[ \t]*(.*?)[ \t]*$`)
+	yamlKeyRe           = regexp.MustCompile(`^([A-Za-z_][\w-]*):(?:[ \t]+(.*?))?[ \t]*$`)
+	yamlListItemRe      = regexp.MustCompile(`^[ \t]+-[ \t]+(.*?)[ \t]*$`)
+)
+
+// inputExtensions maps file extensions to input formats.
+var inputExtensions = map[string]string{
+	".org":      InputOrg,
+	".md":       InputMarkdown,
+	".markdown": InputMarkdown,
+	".adoc":     InputAsciiDoc,
+	".asciidoc": InputAsciiDoc,
+	".rst":      InputRST,
+}
+
+func validateInputFormat(from string) error {
+	switch from {
+	case "", InputOrg, InputMarkdown, InputAsciiDoc, InputRST:
+		return nil
+	}
+	return fmt.Errorf("unsupported input format: %s (expected %q, %q, %q or %q)", from, InputOrg, InputMarkdown, InputAsciiDoc, InputRST)
+}
+
+// detectInputFormat returns from if it is given, or else the format the
+// file extension of path stands for.
+func detectInputFormat(path, from string) (string, error) {
+	if from != "" {
+		return from, validateInputFormat(from)
+	}
+	if format, ok := inputExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("cannot detect the input format of %s; use -from", path)
+}
+
+// readSourceFile reads a file to be posted, of any input format, as UTF-8
+// (see decodeText).
+func readSourceFile(path, encoding string) (string, error) {
+	if !fileExists(path) {
+		return "", fmt.Errorf("file not found: %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %v", err)
+	}
+	text, err := decodeText(data, encoding)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", path, err)
+	}
+	return text, nil
+}
+
+// stdinFile is the -file value that reads the post from standard input.
+const stdinFile = "-"
+
+// postSource is the text of a post and where it came from.
+type postSource struct {
+	// Path is the absolute path of the file, or "" for standard input.
+	Path    string
+	Format  string
+	Content string
+	// BaseDir is the directory relative paths in the post resolve from.
+	BaseDir string
+}
+
+// readPostSource reads file, or stdin when file is "-". The input format is
+// from, or is detected from the file extension; standard input is org
+// unless from says otherwise. baseDir overrides the directory of the file,
+// which for standard input is the current directory. The text is decoded
+// with encoding as the fallback (see decodeText).
+func readPostSource(file, from, baseDir, encoding string, stdin io.Reader) (*postSource, error) {
+	source := &postSource{Format: from}
+
+	if file == stdinFile {
+		if source.Format == "" {
+			source.Format = InputOrg
+		} else if err := validateInputFormat(from); err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read standard input: %v", err)
+		}
+		if source.Content, err = decodeText(data, encoding); err != nil {
+			return nil, fmt.Errorf("standard input: %v", err)
+		}
+		if baseDir == "" {
+			baseDir = "."
+		}
+	} else {
+		absPath, err := getAbsPath(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		}
+		if source.Format, err = detectInputFormat(absPath, from); err != nil {
+			return nil, err
+		}
+		if source.Content, err = readSourceFile(absPath, encoding); err != nil {
+			return nil, err
+		}
+		source.Path = absPath
+		if baseDir == "" {
+			baseDir = filepath.Dir(absPath)
+		}
+	}
+
+	absDir, err := getAbsPath(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	source.BaseDir = absDir
+	return source, nil
+}
+
+// sourceDocument is a post written in markdown, AsciiDoc or
+// reStructuredText, with its metadata taken from the format's own header:
+// YAML front matter, AsciiDoc attributes or an RST title and field list.
+type sourceDocument struct {
+	Format string
+	// Content is the text to convert; YAML front matter is removed from it.
+	Content     string
+	Title       string
+	Tags        []string
+	Date        string
+	Description string
+	Author      string
+}
+
+func parseSourceDocument(content, format string) *sourceDocument {
+	doc := &sourceDocument{Format: format, Content: content}
+	switch format {
+	case InputMarkdown:
+		parseMarkdownFrontMatter(doc)
+	case InputAsciiDoc:
+		parseAsciiDocHeader(doc)
+	case InputRST:
+		parseRSTHeader(doc)
+	}
+	return doc
+}
+
+// postTitle returns the title of the post, or "Untitled".
+func (d *sourceDocument) postTitle() string {
+	if d.Title != "" {
+		return d.Title
+	}
+	return "Untitled"
+}
+
+// splitTagList splits a comma-separated tag list.
+func splitTagList(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseMarkdownFrontMatter reads title, tags (or categories), date, author
+// and description (or summary) from YAML front matter and removes it from the content. Only the flat subset
+// of YAML front matter uses is understood: scalars and lists, either
+// inline ([a, b]) or as "- item" lines.
+func parseMarkdownFrontMatter(doc *sourceDocument) {
+	lines := strings.Split(doc.Content, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return
+	}
+
+	meta := map[string][]string{}
+	key := ""
+	for _, line := range lines[1:end] {
+		if m := yamlListItemRe.FindStringSubmatch(line); m != nil && key != "" {
+			meta[key] = append(meta[key], unquoteYAML(m[1]))
+			continue
+		}
+		m := yamlKeyRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key = strings.ToLower(m[1])
+		value := m[2]
+		switch {
+		case value == "":
+			meta[key] = nil
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for _, item := range splitTagList(value[1 : len(value)-1]) {
+				meta[key] = append(meta[key], unquoteYAML(item))
+			}
+		default:
+			meta[key] = []string{unquoteYAML(value)}
+		}
+	}
+
+	if title := meta["title"]; len(title) > 0 {
+		doc.Title = title[0]
+	}
+	for _, key := range []string{"tags", "categories", "category"} {
+		for _, value := range meta[key] {
+			doc.Tags = append(doc.Tags, splitTagList(value)...)
+		}
+	}
+	if date := meta["date"]; len(date) > 0 {
+		doc.Date = date[0]
+	}
+	if author := meta["author"]; len(author) > 0 {
+		doc.Author = author[0]
+	}
+	for _, key := range []string{"description", "summary"} {
+		if description := meta[key]; len(description) > 0 && doc.Description == "" {
+			doc.Description = description[0]
+		}
+	}
+	doc.Content = strings.TrimLeft(strings.Join(lines[end+1:], "\n"), "\n")
+}
+
+func unquoteYAML(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// parseAsciiDocHeader reads the "= Title" line, the author line and the
+// :tags: (or :keywords:), :revdate: (or :date:), :author: and :description:
+// attributes of the document header.
+func parseAsciiDocHeader(doc *sourceDocument) {
+	lines := strings.Split(doc.Content, "\n")
+	i := 0
+	for i < len(lines) && (strings.TrimSpace(lines[i]) == "" || strings.HasPrefix(lines[i], "//")) {
+		i++
+	}
+	if i < len(lines) && strings.HasPrefix(lines[i], "= ") {
+		doc.Title = strings.TrimSpace(lines[i][2:])
+		i++
+		// The author line, "Name <email>", may follow the title
+		if i < len(lines) && strings.TrimSpace(lines[i]) != "" && !strings.HasPrefix(lines[i], ":") && !strings.HasPrefix(lines[i], "//") {
+			name, _, _ := strings.Cut(lines[i], "<")
+			doc.Author = strings.TrimSpace(name)
+			i++
+		}
+	}
+
+	// The header ends at the first blank line
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		m := asciiDocAttributeRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		switch strings.ToLower(m[1]) {
+		case "tags", "keywords":
+			doc.Tags = append(doc.Tags, splitTagList(m[2])...)
+		case "revdate", "date":
+			doc.Date = m[2]
+		case "description":
+			doc.Description = m[2]
+		case "author":
+			doc.Author = m[2]
+		}
+	}
+}
+
+// parseRSTHeader reads the document title, a section title at the top of
+// the file, and the :tags: (or :category:), :date:, :author: and
+// :description: fields of the field list that follows it.
+func parseRSTHeader(doc *sourceDocument) {
+	lines := strings.Split(doc.Content, "\n")
+	i := 0
+	for i < len(lines) && (strings.TrimSpace(lines[i]) == "" || strings.HasPrefix(lines[i], "..")) {
+		i++
+	}
+
+	if i < len(lines) && isRSTAdornment(lines[i]) {
+		// An overline
+		i++
+	}
+	if i+1 < len(lines) && strings.TrimSpace(lines[i]) != "" && isRSTAdornment(lines[i+1]) {
+		doc.Title = strings.TrimSpace(lines[i])
+		i += 2
+	}
+
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		m := rstFieldRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		switch strings.ToLower(m[1]) {
+		case "tags", "category", "categories":
+			doc.Tags = append(doc.Tags, splitTagList(m[2])...)
+		case "date":
+			doc.Date = m[2]
+		case "description", "summary":
+			doc.Description = m[2]
+		case "author":
+			doc.Author = m[2]
+		}
+	}
+}
+
+// isRSTAdornment reports whether line is a section adornment: three or more
+// of the same punctuation character.
+func isRSTAdornment(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	if len(line) < 3 || !strings.ContainsRune("=-~^\"'`#*+_.:<>", rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// pandocReaderVersions are the pandoc releases that first read an input
+// format, for formats that older releases cannot read.
+var pandocReaderVersions = map[string]string{
+	InputAsciiDoc: "3.7",
+}
+
+// checkPandocReader fails, naming the pandoc release needed, when the
+// installed pandoc cannot read format.
+func checkPandocReader(format string) error {
+	version, ok := pandocReaderVersions[format]
+	if !ok {
+		return nil
+	}
+	output, err := exec.Command("pandoc", "--list-input-formats").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list pandoc input formats: %v", err)
+	}
+	for _, name := range strings.Fields(string(output)) {
+		if name == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("pandoc cannot read %s: pandoc %s or later is needed", format, version)
+}
+
+// orgOnlyOptions returns the configured options that only apply to org
+// files and are ignored for other input formats.
+func orgOnlyOptions(opts ConvertOptions) []string {
+	var names []string
+	if opts.JoinCJKLines {
+		names = append(names, "join_cjk_lines")
+	}
+	if opts.CJKEmphasis {
+		names = append(names, "cjk_emphasis")
+	}
+	if opts.Math != "" && opts.Math != MathNone {
+		names = append(names, "math")
+	}
+	if opts.Footnotes == FootnotesHatena {
+		names = append(names, "footnotes")
+	}
+	if opts.NumberFigures {
+		names = append(names, "number_figures")
+	}
+	if opts.HeadingOffset != 0 {
+		names = append(names, "heading_offset")
+	}
+	return names
+}
+
+// convertSourceContent converts a markdown, AsciiDoc or reStructuredText
+// document into the requested output format. Markdown for markdown output
+// is posted as it was written. pandoc runs in opts.BaseDir so that include
+// directives resolve.
+func convertSourceContent(doc *sourceDocument, opts ConvertOptions) (string, error) {
+	if doc.Format == InputMarkdown && (opts.Format == "" || opts.Format == FormatMarkdown) {
+		return doc.Content, nil
+	}
+	if err := checkPandocReader(doc.Format); err != nil {
+		return "", err
+	}
+
+	switch opts.Format {
+	case "", FormatMarkdown:
+		output, err := runPandoc(opts.BaseDir, doc.Content, "-f", doc.Format, "-t", "markdown", "--wrap=preserve")
+		if err != nil {
+			return "", err
+		}
+		return unwrapRawExports(output), nil
+	case FormatHTML:
+		output, err := runPandoc(opts.BaseDir, doc.Content, "-f", doc.Format, "-t", "html", "--wrap=preserve", "--no-highlight")
+		if err != nil {
+			return "", err
+		}
+		htmlContent := removeHTMLHeadingIDs(output)
+		htmlContent = convertHTMLCodeBlocks(htmlContent)
+		return htmlContent, nil
+	default:
+		return "", fmt.Errorf("unsupported output format: %s", opts.Format)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectInputFormat(t *testing.T) {
+	tests := []struct {
+		path     string
+		from     string
+		expected string
+		wantErr  bool
+	}{
+		{"post.org", "", InputOrg, false},
+		{"post.md", "", InputMarkdown, false},
+		{"post.Markdown", "", InputMarkdown, false},
+		{"post.adoc", "", InputAsciiDoc, false},
+		{"post.rst", "", InputRST, false},
+		{"post.txt", "", "", true},
+		{"post.txt", InputRST, InputRST, false},
+		{"post.md", InputOrg, InputOrg, false},
+		{"post.md", "textile", "", true},
+	}
+
+	for _, test := range tests {
+		format, err := detectInputFormat(test.path, test.from)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("detectInputFormat(%q, %q) should fail", test.path, test.from)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("detectInputFormat(%q, %q) failed: %v", test.path, test.from, err)
+		} else if format != test.expected {
+			t.Errorf("detectInputFormat(%q, %q) = %q, expected %q", test.path, test.from, format, test.expected)
+		}
+	}
+}
+
+func TestParseSourceDocument(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		content  string
+		expected sourceDocument
+	}{
+		{
+			name:   "YAML front matter with a block list",
+			format: InputMarkdown,
+			content: `---
+title: "Hello, world"
+date: 2024-01-02
+tags:
+  - go
+  - Web Development
+draft: true
+description: 記事の概要
+---
+
+Body text.
+`,
+			expected: sourceDocument{
+				Content:     "Body text.\n",
+				Title:       "Hello, world",
+				Tags:        []string{"go", "Web Development"},
+				Date:        "2024-01-02",
+				Description: "記事の概要",
+			},
+		},
+		{
+			name:   "YAML front matter with inline lists",
+			format: InputMarkdown,
+			content: `---
+title: Inline
+categories: [blog, 'emacs']
+tags: go, org
+---
+# Body`,
+			expected: sourceDocument{
+				Content: "# Body",
+				Title:   "Inline",
+				Tags:    []string{"go", "org", "blog", "emacs"},
+			},
+		},
+		{
+			name:     "markdown without front matter",
+			format:   InputMarkdown,
+			content:  "# Heading\n\nBody\n",
+			expected: sourceDocument{Content: "# Heading\n\nBody\n"},
+		},
+		{
+			name:   "AsciiDoc header",
+			format: InputAsciiDoc,
+			content: `// a comment
+= Document Title
+Author Name <author@example.com>
+:revdate: 2024-01-02
+:tags: go, emacs
+
+:tags: not-in-header
+Body`,
+			expected: sourceDocument{
+				Title:  "Document Title",
+				Tags:   []string{"go", "emacs"},
+				Date:   "2024-01-02",
+				Author: "Author Name",
+			},
+		},
+		{
+			name:   "RST title and field list",
+			format: InputRST,
+			content: `==========
+RST Title
+==========
+
+:date: 2024-01-02 10:30
+:author: hanako
+:tags: go, rst
+
+Body
+`,
+			expected: sourceDocument{
+				Title:  "RST Title",
+				Tags:   []string{"go", "rst"},
+				Date:   "2024-01-02 10:30",
+				Author: "hanako",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		doc := parseSourceDocument(test.content, test.format)
+		if test.expected.Content == "" {
+			// Only markdown front matter is removed from the content
+			test.expected.Content = test.content
+		}
+		test.expected.Format = test.format
+		if !reflect.DeepEqual(*doc, test.expected) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, *doc, test.expected)
+		}
+	}
+}
+
+func TestConvertSourceContentKeepsMarkdown(t *testing.T) {
+	doc := parseSourceDocument("---\ntitle: Post\n---\n\n*Hello* `code`\n", InputMarkdown)
+	converted, err := convertSourceContent(doc, ConvertOptions{Format: FormatMarkdown})
+	if err != nil {
+		t.Fatalf("convertSourceContent failed: %v", err)
+	}
+	if converted != "*Hello* `code`\n" {
+		t.Errorf("Markdown should be posted as written, got %q", converted)
+	}
+}
+
+func TestCheckPandocReader(t *testing.T) {
+	// Formats every pandoc reads are not checked at all
+	if err := checkPandocReader(InputRST); err != nil {
+		t.Errorf("reStructuredText should not need a newer pandoc: %v", err)
+	}
+	if !isPandocAvailable() {
+		t.Skip("pandoc not available")
+	}
+	if err := checkPandocReader(InputAsciiDoc); err != nil && !strings.Contains(err.Error(), "pandoc 3.7 or later") {
+		t.Errorf("The error should name the pandoc release needed: %v", err)
+	}
+}
+
+func TestOrgOnlyOptions(t *testing.T) {
+	if names := orgOnlyOptions(ConvertOptions{Math: MathNone, Footnotes: FootnotesMarkdown}); names != nil {
+		t.Errorf("Expected no org-only options, got %v", names)
+	}
+	opts := ConvertOptions{JoinCJKLines: true, Math: MathMathJax, HeadingOffset: 1}
+	if names := orgOnlyOptions(opts); !reflect.DeepEqual(names, []string{"join_cjk_lines", "math", "heading_offset"}) {
+		t.Errorf("Unexpected org-only options %v", names)
+	}
+}
+
+func TestReadPostSource(t *testing.T) {
+	tempDir := t.TempDir()
+	postFile := filepath.Join(tempDir, "post.md")
+	if err := os.WriteFile(postFile, []byte("# Post\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		from     string
+		baseDir  string
+		expected postSource
+		wantErr  bool
+	}{
+		{
+			name:     "file",
+			file:     postFile,
+			expected: postSource{Path: postFile, Format: InputMarkdown, Content: "# Post\n", BaseDir: tempDir},
+		},
+		{
+			name:     "file with base directory",
+			file:     postFile,
+			baseDir:  cwd,
+			expected: postSource{Path: postFile, Format: InputMarkdown, Content: "# Post\n", BaseDir: cwd},
+		},
+		{
+			name:     "stdin defaults to org and the current directory",
+			file:     "-",
+			expected: postSource{Format: InputOrg, Content: "#+TITLE: Piped\n", BaseDir: cwd},
+		},
+		{
+			name:     "stdin with format and base directory",
+			file:     "-",
+			from:     InputRST,
+			baseDir:  tempDir,
+			expected: postSource{Format: InputRST, Content: "#+TITLE: Piped\n", BaseDir: tempDir},
+		},
+		{
+			name:    "stdin with an unknown format",
+			file:    "-",
+			from:    "textile",
+			wantErr: true,
+		},
+		{
+			name:    "missing file",
+			file:    filepath.Join(tempDir, "missing.org"),
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		source, err := readPostSource(test.file, test.from, test.baseDir, "", strings.NewReader("#+TITLE: Piped\n"))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: readPostSource failed: %v", test.name, err)
+		} else if !reflect.DeepEqual(*source, test.expected) {
+			t.Errorf("%s: got %+v, expected %+v", test.name, *source, test.expected)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	orgLinkRe   = regexp.MustCompile(`\[\[([^\[\]]+)\](?:\[([^\[\]]*)\])?\]`)
+	orgTargetRe = regexp.MustCompile(`(^|[^<])<<([^<>\n]+)>>`)
+	orgTOCRe    = regexp.MustCompile(`(?i)^\s*#\+TOC:[ \t]*(.*?)[ \t]*$`)
+)
+
+// hatenaTOC is the Hatena notation for a table of contents, written as an
+// HTML export block so that it reaches Hatena unchanged.
+var hatenaTOC = []string{"#+begin_export html", "[:contents]", "#+end_export"}
+
+// resolveOrgInternalLinks rewrites links to headings ([[*Heading]],
+// [[#custom-id]]) and to <<targets>> ([[target]]) as links to explicit
+// anchors, since Hatena generates its own heading IDs and pandoc's are
+// removed. An anchor is injected into every heading that is linked to, and
+// targets are replaced by anchors. Links that cannot be resolved are warned
+// about and left alone.
+func resolveOrgInternalLinks(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	headings := parseOrgHeadings(lines)
+
+	anchors := map[*orgHeading]string{}
+	headingAnchor := func(h *orgHeading, index int) string {
+		if anchor, ok := anchors[h]; ok {
+			return anchor
+		}
+		anchor := h.Properties["CUSTOM_ID"]
+		if anchor == "" {
+			anchor = "sec-" + strconv.Itoa(index+1)
+		}
+		anchors[h] = anchor
+		return anchor
+	}
+
+	targets := map[string]bool{}
+	inBlock := false
+	for _, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		for _, m := range orgTargetRe.FindAllStringSubmatch(line, -1) {
+			targets[strings.TrimSpace(m[2])] = true
+		}
+	}
+
+	resolve := func(link, description string) (string, bool) {
+		var anchor, text string
+		switch {
+		case strings.HasPrefix(link, "*"):
+			title := strings.TrimSpace(link[1:])
+			for i, h := range headings {
+				if h.Title == title {
+					anchor, text = headingAnchor(h, i), h.Title
+					break
+				}
+			}
+		case strings.HasPrefix(link, "#"):
+			for i, h := range headings {
+				if h.Properties["CUSTOM_ID"] == link[1:] {
+					anchor, text = headingAnchor(h, i), h.Title
+					break
+				}
+			}
+		default:
+			if targets[link] {
+				anchor, text = targetAnchor(link), link
+			} else {
+				// A fuzzy link may also refer to a heading by its title
+				for i, h := range headings {
+					if h.Title == link {
+						anchor, text = headingAnchor(h, i), h.Title
+						break
+					}
+				}
+			}
+		}
+		if anchor == "" {
+			return "", false
+		}
+		if description == "" {
+			description = text
+		}
+		return "[[#" + anchor + "][" + description + "]]", true
+	}
+
+	inBlock = false
+	for i, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		lines[i] = orgLinkRe.ReplaceAllStringFunc(line, func(link string) string {
+			m := orgLinkRe.FindStringSubmatch(link)
+			target := strings.TrimSpace(m[1])
+			isInternal := strings.HasPrefix(target, "*") || strings.HasPrefix(target, "#")
+			if !isInternal && (strings.Contains(target, ":") || !targets[target] && !isHeadingTitle(headings, target)) {
+				// URLs, files and other links are left to pandoc
+				return link
+			}
+			resolved, ok := resolve(target, m[2])
+			if !ok {
+				warnf("cannot resolve internal link [[%s]]", target)
+				return link
+			}
+			return resolved
+		})
+		lines[i] = orgTargetRe.ReplaceAllStringFunc(lines[i], func(target string) string {
+			m := orgTargetRe.FindStringSubmatch(target)
+			return m[1] + "@@html:" + `<span id="` + html.EscapeString(targetAnchor(strings.TrimSpace(m[2]))) + `"></span>` + "@@"
+		})
+	}
+
+	for h, anchor := range anchors {
+		lines[h.start] = injectHeadingAnchor(lines[h.start], anchor)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func isHeadingTitle(headings []*orgHeading, title string) bool {
+	for _, h := range headings {
+		if h.Title == title {
+			return true
+		}
+	}
+	return false
+}
+
+// targetAnchor returns the anchor of a <<target>>; whitespace is not
+// allowed in IDs.
+func targetAnchor(target string) string {
+	return "target-" + strings.Join(strings.Fields(target), "-")
+}
+
+// injectHeadingAnchor puts an empty anchor element at the end of the
+// headline text, before any tags.
+func injectHeadingAnchor(line, anchor string) string {
+	snippet := "@@html:" + `<span id="` + html.EscapeString(anchor) + `"></span>` + "@@"
+	line = strings.TrimRight(line, " \t")
+	if tm := orgHeadingTagsRe.FindStringIndex(line); tm != nil {
+		return line[:tm[0]] + " " + snippet + line[tm[0]:]
+	}
+	return line + " " + snippet
+}
+
+// insertOrgTableOfContents replaces #+TOC: keywords with Hatena's
+// [:contents], and with toc set in #+OPTIONS: also puts one before the
+// first heading, where org places its table of contents.
+func insertOrgTableOfContents(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	opts := parseOrgExportOptions(lines)
+	var result []string
+	tocInserted := opts.TOC == 0
+	inBlock := false
+
+	for _, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			result = append(result, line)
+			continue
+		}
+		if m := orgTOCRe.FindStringSubmatch(line); m != nil {
+			if strings.HasPrefix(strings.ToLower(m[1]), "headlines") {
+				result = append(result, hatenaTOC...)
+			}
+			// Lists of tables or listings have no Hatena equivalent
+			continue
+		}
+		if !tocInserted && isOrgHeading(line) {
+			result = append(result, hatenaTOC...)
+			result = append(result, "")
+			tocInserted = true
+		}
+		result = append(result, line)
+	}
+
+	return strings.Join(result, "\n")
+}
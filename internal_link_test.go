@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestResolveOrgInternalLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "heading link",
+			input:    "See [[*Setup]].\n* Intro\n* Setup :tips:",
+			expected: "See [[#sec-2][Setup]].\n* Intro\n* Setup @@html:<span id=\"sec-2\"></span>@@ :tips:",
+		},
+		{
+			name:     "custom id link with description",
+			input:    "* 設定\n:PROPERTIES:\n:CUSTOM_ID: config\n:END:\n[[#config][設定方法]]を参照",
+			expected: "* 設定 @@html:<span id=\"config\"></span>@@\n:PROPERTIES:\n:CUSTOM_ID: config\n:END:\n[[#config][設定方法]]を参照",
+		},
+		{
+			name:     "target link",
+			input:    "Here is <<the point>>.\nGo to [[the point][there]].",
+			expected: "Here is @@html:<span id=\"target-the-point\"></span>@@.\nGo to [[#target-the-point][there]].",
+		},
+		{
+			name:     "fuzzy heading link",
+			input:    "* Usage\n[[Usage]]",
+			expected: "* Usage @@html:<span id=\"sec-1\"></span>@@\n[[#sec-1][Usage]]",
+		},
+		{
+			name:     "external and unresolved links untouched",
+			input:    "[[https://example.com][x]] [[file:a.org]] [[*Missing]] [[nothing]]",
+			expected: "[[https://example.com][x]] [[file:a.org]] [[*Missing]] [[nothing]]",
+		},
+		{
+			name:     "radio targets and blocks untouched",
+			input:    "<<<radio>>>\n#+begin_src org\n<<t>> [[*x]]\n#+end_src",
+			expected: "<<<radio>>>\n#+begin_src org\n<<t>> [[*x]]\n#+end_src",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := resolveOrgInternalLinks(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestInsertOrgTableOfContents(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "toc keyword",
+			input:    "intro\n#+TOC: headlines 2\n* A",
+			expected: "intro\n#+begin_export html\n[:contents]\n#+end_export\n* A",
+		},
+		{
+			name:     "toc option",
+			input:    "#+OPTIONS: toc:t\nintro\n* A\n* B",
+			expected: "#+OPTIONS: toc:t\nintro\n#+begin_export html\n[:contents]\n#+end_export\n\n* A\n* B",
+		},
+		{
+			name:     "other lists dropped",
+			input:    "#+TOC: tables\n* A",
+			expected: "* A",
+		},
+		{
+			name:     "no toc",
+			input:    "#+OPTIONS: toc:nil\n* A",
+			expected: "#+OPTIONS: toc:nil\n* A",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := insertOrgTableOfContents(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxMacroDepth bounds macro expansion so that a macro expanding to itself
+// cannot loop forever.
+const maxMacroDepth = 10
+
+var (
+	orgMacroCallRe   = regexp.MustCompile(`\{\{\{([a-zA-Z][\w-]*)(?:\(((?s:.*?))\))?\}\}\}`)
+	orgMacroArgRe    = regexp.MustCompile(`\$(\d)`)
+	orgLinkAbbrevRe  = regexp.MustCompile(`\[\[([a-zA-Z][\w-]*):([^\]]*)\]`)
+	orgMacroDefineRe = regexp.MustCompile(`^(\S+)(?:[ \t]+(.*))?$`)
+)
+
+// expandOrgMacros expands {{{macro}}} calls and link abbreviations such as
+// [[gh:owner/repo]] outside of blocks. Macros and abbreviations defined in
+// the document with #+MACRO: and #+LINK: take precedence over the global
+// ones passed in.
+func expandOrgMacros(orgContent string, globalMacros, globalLinks map[string]string, now time.Time) string {
+	lines := strings.Split(orgContent, "\n")
+
+	macros := map[string]string{}
+	links := map[string]string{}
+	for name, body := range globalMacros {
+		macros[name] = body
+	}
+	for key, link := range globalLinks {
+		links[key] = link
+	}
+
+	keywords := map[string][]string{}
+	inBlock := false
+	for _, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		m := orgKeywordRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := strings.ToUpper(m[1])
+		keywords[key] = append(keywords[key], m[2])
+
+		switch key {
+		case "MACRO":
+			if d := orgMacroDefineRe.FindStringSubmatch(m[2]); d != nil {
+				macros[d[1]] = d[2]
+			}
+		case "LINK":
+			if d := orgMacroDefineRe.FindStringSubmatch(m[2]); d != nil {
+				links[d[1]] = strings.TrimSpace(d[2])
+			}
+		}
+	}
+
+	expander := &orgMacroExpander{
+		macros:   macros,
+		keywords: keywords,
+		counters: map[string]int{},
+		now:      now,
+	}
+
+	inBlock = false
+	for i, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		if m := orgKeywordRe.FindStringSubmatch(line); m != nil {
+			switch strings.ToUpper(m[1]) {
+			case "MACRO", "LINK":
+				continue
+			}
+		}
+		line = expander.expand(line, 0)
+		lines[i] = expandOrgLinkAbbrevs(line, links)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// expandOrgMacrosInValue expands the macros in a value taken from the
+// document's metadata, such as its title or description, with the
+// definitions of orgContent, as org-export does for #+TITLE:.
+func expandOrgMacrosInValue(value, orgContent string, opts ConvertOptions) string {
+	if !strings.Contains(value, "{{{") {
+		return value
+	}
+	// Definitions are collected from the whole text, so the value can
+	// come first, out of any block the document leaves open
+	n := len(strings.Split(value, "\n"))
+	expanded := strings.Split(expandOrgMacros(value+"\n"+orgContent, opts.Macros, opts.LinkAbbrevs, time.Now()), "\n")
+	return strings.Join(expanded[:n], "\n")
+}
+
+type orgMacroExpander struct {
+	macros   map[string]string
+	keywords map[string][]string
+	counters map[string]int
+	now      time.Time
+}
+
+func (e *orgMacroExpander) expand(text string, depth int) string {
+	if depth >= maxMacroDepth {
+		warnf("macro expansion too deep in %q", text)
+		return text
+	}
+	return orgMacroCallRe.ReplaceAllStringFunc(text, func(call string) string {
+		m := orgMacroCallRe.FindStringSubmatch(call)
+		name := strings.ToLower(m[1])
+		args := splitOrgMacroArgs(m[2])
+
+		value, ok := e.builtin(name, args)
+		if !ok {
+			body, defined := e.lookup(m[1])
+			if !defined {
+				warnf("undefined macro {{{%s}}}", m[1])
+				return call
+			}
+			if strings.HasPrefix(strings.TrimSpace(body), "(eval") {
+				warnf("macro {{{%s}}} uses eval, which is not supported", m[1])
+				return call
+			}
+			value = orgMacroArgRe.ReplaceAllStringFunc(body, func(ref string) string {
+				n, _ := strconv.Atoi(ref[1:])
+				if n >= 1 && n <= len(args) {
+					return args[n-1]
+				}
+				return ""
+			})
+		}
+		return e.expand(value, depth+1)
+	})
+}
+
+func (e *orgMacroExpander) lookup(name string) (string, bool) {
+	if body, ok := e.macros[name]; ok {
+		return body, true
+	}
+	for key, body := range e.macros {
+		if strings.EqualFold(key, name) {
+			return body, true
+		}
+	}
+	return "", false
+}
+
+// builtin evaluates org's predefined macros.
+func (e *orgMacroExpander) builtin(name string, args []string) (string, bool) {
+	switch name {
+	case "title", "author", "email":
+		return strings.Join(e.keywords[strings.ToUpper(name)], " "), true
+	case "keyword":
+		if len(args) == 0 {
+			return "", true
+		}
+		return strings.Join(e.keywords[strings.ToUpper(args[0])], " "), true
+	case "date":
+		date := strings.Join(e.keywords["DATE"], " ")
+		if len(args) == 0 || args[0] == "" {
+			return date, true
+		}
+		t, err := parseOrgDate(date)
+		if err != nil {
+			return date, true
+		}
+		return formatOrgTime(t, args[0]), true
+	case "time":
+		format := "%Y-%m-%d %H:%M"
+		if len(args) > 0 && args[0] != "" {
+			format = args[0]
+		}
+		return formatOrgTime(e.now, format), true
+	case "n":
+		counter := ""
+		if len(args) > 0 {
+			counter = args[0]
+		}
+		action := ""
+		if len(args) > 1 {
+			action = args[1]
+		}
+		switch {
+		case action == "-":
+			return strconv.Itoa(e.counters[counter]), true
+		case action != "":
+			if n, err := strconv.Atoi(action); err == nil {
+				e.counters[counter] = n
+				return action, true
+			}
+		}
+		e.counters[counter]++
+		return strconv.Itoa(e.counters[counter]), true
+	}
+	return "", false
+}
+
+// splitOrgMacroArgs splits macro arguments on commas, honoring "\," as an
+// escaped comma.
+func splitOrgMacroArgs(argString string) []string {
+	if argString == "" {
+		return nil
+	}
+
+	var args []string
+	var current strings.Builder
+	for i := 0; i < len(argString); i++ {
+		if argString[i] == '\\' && i+1 < len(argString) && argString[i+1] == ',' {
+			current.WriteByte(',')
+			i++
+			continue
+		}
+		if argString[i] == ',' {
+			args = append(args, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+		current.WriteByte(argString[i])
+	}
+	return append(args, strings.TrimSpace(current.String()))
+}
+
+// expandOrgLinkAbbrevs rewrites [[key:tag]] links whose key is a known
+// abbreviation. As in org, %s in the replacement is substituted by the tag,
+// %h by the URL-encoded tag, and otherwise the tag is appended.
+func expandOrgLinkAbbrevs(line string, links map[string]string) string {
+	if len(links) == 0 {
+		return line
+	}
+	return orgLinkAbbrevRe.ReplaceAllStringFunc(line, func(link string) string {
+		m := orgLinkAbbrevRe.FindStringSubmatch(link)
+		replacement, ok := links[m[1]]
+		if !ok {
+			return link
+		}
+		var target string
+		switch {
+		case strings.Contains(replacement, "%s"):
+			target = strings.ReplaceAll(replacement, "%s", m[2])
+		case strings.Contains(replacement, "%h"):
+			target = strings.ReplaceAll(replacement, "%h", url.QueryEscape(m[2]))
+		default:
+			target = replacement + m[2]
+		}
+		return "[[" + target + "]"
+	})
+}
+
+// formatOrgTime formats t with the strftime-style directives org uses in
+// {{{time(...)}}} and {{{date(...)}}}.
+func formatOrgTime(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'Y':
+			b.WriteString(t.Format("2006"))
+		case 'y':
+			b.WriteString(t.Format("06"))
+		case 'm':
+			b.WriteString(t.Format("01"))
+		case 'd':
+			b.WriteString(t.Format("02"))
+		case 'e':
+			b.WriteString(fmt.Sprintf("%2d", t.Day()))
+		case 'H':
+			b.WriteString(t.Format("15"))
+		case 'I':
+			b.WriteString(t.Format("03"))
+		case 'M':
+			b.WriteString(t.Format("04"))
+		case 'S':
+			b.WriteString(t.Format("05"))
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'b', 'h':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case 'R':
+			b.WriteString(t.Format("15:04"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(format[i])
+		}
+	}
+	return b.String()
+}
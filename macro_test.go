@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandOrgMacros(t *testing.T) {
+	now := time.Date(2024, 3, 5, 14, 7, 0, 0, time.UTC)
+	globalMacros := map[string]string{
+		"tool":  "hatena-blog-org",
+		"greet": "global $1",
+	}
+	globalLinks := map[string]string{
+		"wiki": "https://ja.wikipedia.org/wiki/",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "document macro with arguments",
+			input:    "#+MACRO: kbd @@html:<kbd>$1</kbd>@@ + $2\nPress {{{kbd(C-c, C-e)}}}.",
+			expected: "#+MACRO: kbd @@html:<kbd>$1</kbd>@@ + $2\nPress @@html:<kbd>C-c</kbd>@@ + C-e.",
+		},
+		{
+			name:     "escaped comma in argument",
+			input:    "#+MACRO: q \"$1\"\n{{{q(a\\, b)}}}",
+			expected: "#+MACRO: q \"$1\"\n\"a, b\"",
+		},
+		{
+			name:     "global macro",
+			input:    "Posted with {{{tool}}}.",
+			expected: "Posted with hatena-blog-org.",
+		},
+		{
+			name:     "document macro overrides global",
+			input:    "#+MACRO: greet local $1\n{{{greet(there)}}}",
+			expected: "#+MACRO: greet local $1\nlocal there",
+		},
+		{
+			name:     "nested macros",
+			input:    "#+MACRO: outer <{{{tool}}}>\n{{{outer}}}",
+			expected: "#+MACRO: outer <{{{tool}}}>\n<hatena-blog-org>",
+		},
+		{
+			name:     "title, author and keyword",
+			input:    "#+TITLE: My Post\n#+AUTHOR: garaemon\n#+BLOG: tech\n{{{title}}} by {{{author}}} in {{{keyword(BLOG)}}}",
+			expected: "#+TITLE: My Post\n#+AUTHOR: garaemon\n#+BLOG: tech\nMy Post by garaemon in tech",
+		},
+		{
+			name:     "date with format",
+			input:    "#+DATE: <2024-01-02 Tue>\n{{{date}}} / {{{date(%Y年%m月%d日)}}}",
+			expected: "#+DATE: <2024-01-02 Tue>\n<2024-01-02 Tue> / 2024年01月02日",
+		},
+		{
+			name:     "time",
+			input:    "Updated {{{time(%Y-%m-%d %H:%M)}}}",
+			expected: "Updated 2024-03-05 14:07",
+		},
+		{
+			name:     "counters",
+			input:    "{{{n}}} {{{n}}} {{{n(fig)}}} {{{n(fig,-)}}} {{{n(fig,10)}}} {{{n(fig)}}}",
+			expected: "1 2 1 1 10 11",
+		},
+		{
+			name:     "undefined macro left as is",
+			input:    "{{{unknown(x)}}}",
+			expected: "{{{unknown(x)}}}",
+		},
+		{
+			name:     "macros in src blocks untouched",
+			input:    "#+begin_src org\n{{{tool}}}\n#+end_src",
+			expected: "#+begin_src org\n{{{tool}}}\n#+end_src",
+		},
+		{
+			name:     "link abbreviation with %s",
+			input:    "#+LINK: gh https://github.com/%s\nSee [[gh:garaemon/hatena-blog-org][the repo]].",
+			expected: "#+LINK: gh https://github.com/%s\nSee [[https://github.com/garaemon/hatena-blog-org][the repo]].",
+		},
+		{
+			name:     "link abbreviation with %h",
+			input:    "#+LINK: google https://www.google.com/search?q=%h\n[[google:org mode]]",
+			expected: "#+LINK: google https://www.google.com/search?q=%h\n[[https://www.google.com/search?q=org+mode]]",
+		},
+		{
+			name:     "global link abbreviation appends tag",
+			input:    "[[wiki:Emacs]]",
+			expected: "[[https://ja.wikipedia.org/wiki/Emacs]]",
+		},
+		{
+			name:     "unknown link type untouched",
+			input:    "[[https://example.com][example]] [[file:foo.org]]",
+			expected: "[[https://example.com][example]] [[file:foo.org]]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := expandOrgMacros(tt.input, globalMacros, globalLinks, now)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestExpandOrgMacrosInValue(t *testing.T) {
+	content := "#+TITLE: {{{project}}} 1.0\n#+MACRO: project hatena-blog-org\n#+begin_src org\nunterminated"
+	opts := ConvertOptions{Macros: map[string]string{"me": "garaemon"}}
+
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"{{{project}}} 1.0", "hatena-blog-org 1.0"},
+		{"Notes by {{{me}}}\non {{{project}}}", "Notes by garaemon\non hatena-blog-org"},
+		{"No macros", "No macros"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if result := expandOrgMacrosInValue(tt.value, content, opts); result != tt.expected {
+			t.Errorf("expandOrgMacrosInValue(%q) = %q, expected %q", tt.value, result, tt.expected)
+		}
+	}
+
+	doc := parseOrgDocument(content)
+	if title := expandOrgMacrosInValue(doc.postTitle(), doc.Content, opts); title != "hatena-blog-org 1.0" {
+		t.Errorf("Unexpected post title %q", title)
+	}
+}
+
+func TestFormatOrgTime(t *testing.T) {
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	result := formatOrgTime(tm, "%F %T %a %b %e %% %Q")
+	expected := "2024-01-02 03:04:05 Tue Jan  2 % %Q"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 )
 
 func main() {
 	var (
-		orgFile     = flag.String("file", "", "Path to the .org file")
+		orgFile     = flag.String("file", "", "Path to the .org, markdown, AsciiDoc or reStructuredText file, or - for standard input")
 		hatenaID    = flag.String("id", "", "Hatena ID")
 		apiKey      = flag.String("key", "", "API Key")
 		blogDomain  = flag.String("domain", "", "Blog domain")
@@ -19,6 +20,18 @@ func main() {
 		configFile  = flag.String("config", "", "Path to config file")
 		interactive = flag.Bool("interactive", false, "Interactive mode")
 		debug       = flag.Bool("debug", false, "Enable debug output")
+		format      = flag.String("format", "", "Output format: markdown or html (overrides config)")
+		subtree     = flag.String("subtree", "", "Post only the subtree selected by ID, title or line number")
+		allSubtrees = flag.Bool("all-subtrees", false, "Post every subtree marked as a post")
+		joinLines   = flag.Bool("join-cjk-lines", false, "Join hard-wrapped paragraph lines without spaces between CJK characters")
+		cjkEmphasis = flag.Bool("cjk-emphasis", false, "Recognize emphasis markers next to CJK characters")
+		math        = flag.String("math", "", "Math rendering: tex, mathjax or none (overrides config)")
+		footnotes   = flag.String("footnotes", "", "Footnote style: markdown or hatena (overrides config)")
+		from        = flag.String("from", "", "Input format: org, markdown, asciidoc or rst (default: by file extension)")
+		encoding    = flag.String("encoding", "", "Encoding of the input, e.g. shift_jis or euc-jp (default: detected)")
+		author      = flag.String("author", "", "Hatena ID or name of the group blog member to attribute the post to (overrides #+AUTHOR:)")
+		autoSummary = flag.Int("auto-summary", 0, "Generate a summary of this many characters for posts without #+DESCRIPTION: (overrides config)")
+		baseDir     = flag.String("base-dir", "", "Directory relative include and image paths resolve from (default: the file's directory)")
 	)
 	flag.Parse()
 
@@ -39,12 +52,54 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *format != "" {
+		config.Format = *format
+	}
+	if *math != "" {
+		config.Math = *math
+	}
+	if *footnotes != "" {
+		config.Footnotes = *footnotes
+	}
+	if *author != "" {
+		config.Author = *author
+	}
+	if *autoSummary != 0 {
+		config.AutoSummary = *autoSummary
+	}
+	if *encoding != "" {
+		config.Encoding = *encoding
+	}
+	if *joinLines {
+		config.JoinCJKLines = true
+	}
+	if *cjkEmphasis {
+		config.CJKEmphasis = true
+	}
+
 	if err := validateConfig(config); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	articleURL, err := postOrgFile(*orgFile, config, *category, *isDraft, *debug)
+	if err := validateInputFormat(*from); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *subtree != "" || *allSubtrees {
+		articleURLs, err := postOrgSubtrees(*orgFile, *from, *baseDir, config, *subtree, *allSubtrees, *category, *isDraft, *debug)
+		for _, articleURL := range articleURLs {
+			fmt.Printf("Successfully posted to Hatena Blog!\nEdit URL: %s\n", articleURL)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	articleURL, err := postOrgFile(*orgFile, *from, *baseDir, config, *category, *isDraft, *debug)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -91,7 +146,7 @@ func runInteractiveMode() {
 		os.Exit(1)
 	}
 
-	articleURL, err := postOrgFile(orgFile, config, category, isDraft, false)
+	articleURL, err := postOrgFile(orgFile, "", "", config, category, isDraft, false)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -100,42 +155,268 @@ func runInteractiveMode() {
 	fmt.Printf("Successfully posted to Hatena Blog!\nEdit URL: %s\n", articleURL)
 }
 
-func postOrgFile(orgFile string, config *Config, category string, isDraft bool, debug bool) (string, error) {
-	absPath, err := getAbsPath(orgFile)
+// postOrgFile posts a whole file, or standard input for "-". The input
+// format is from, or is detected from the file extension; inputs other than
+// org go to postSourceFile. Relative paths resolve from baseDir if it is
+// given.
+func postOrgFile(orgFile, from, baseDir string, config *Config, category string, isDraft bool, debug bool) (string, error) {
+	source, err := readPostSource(orgFile, from, baseDir, config.Encoding, os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	if source.Format != InputOrg {
+		return postSourceFile(source, config, category, isDraft, debug)
+	}
+
+	registry, err := loadEntryRegistry(config.entriesPath())
+	if err != nil {
+		return "", err
+	}
+	opts := config.convertOptions()
+	opts.BaseDir = source.BaseDir
+	opts.Entries = registry
+
+	doc, err := parseOrgPostDocument(source.Content, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to get absolute path: %v", err)
+		return "", fmt.Errorf("failed to convert org file: %v", err)
 	}
+	doc.Path = source.Path
 
-	title, err := extractTitleFromOrg(absPath)
+	author, err := entryAuthor(config, doc.Author)
 	if err != nil {
-		return "", fmt.Errorf("failed to extract title from org file: %v", err)
+		return "", err
 	}
 
-	categories, err := extractCategoriesFromOrg(absPath)
+	title := expandOrgMacrosInValue(doc.postTitle(), doc.Content, opts)
+	categories := append([]string{}, doc.FileTags...)
+	if category != "" {
+		categories = append(categories, category)
+	}
+
+	// #+DATE: sets the posting time like the dates of the other formats
+	var updated time.Time
+	if doc.Date != "" {
+		if updated, err = parseOrgDate(expandOrgMacrosInValue(doc.Date, doc.Content, opts)); err != nil {
+			warnf("%v; posting with the current time", err)
+		}
+	}
+
+	converted, err := convertOrgContent(doc.Content, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert org file: %v", err)
+	}
+
+	content := converted
+	if config.Format != FormatHTML {
+		content = removeTitleFromMarkdown(converted)
+	}
+
+	description := doc.Description
+	if description == "" {
+		description = doc.Properties["DESCRIPTION"]
+	}
+	description = expandOrgMacrosInValue(description, doc.Content, opts)
+
+	client := NewHatenaClient(config.HatenaID, config.APIKey, config.BlogDomain)
+	content, err = insertEyecatch(content, doc.keyword("EYECATCH"), source.BaseDir, client, debug)
+	if err != nil {
+		return "", err
+	}
+
+	entry := BlogEntry{
+		Title:       title,
+		Content:     content,
+		ContentType: contentTypeForFormat(config.Format),
+		Categories:  categories,
+		IsDraft:     isDraft,
+		Updated:     updated,
+		Summary:     entrySummary(description, content, config.Format, config.AutoSummary),
+		Author:      author,
+	}
+
+	posted, err := client.PostEntry(entry, debug)
+	if err != nil {
+		return "", err
+	}
+
+	recordPublishedEntry(config, registry, source, PublishedEntry{
+		ID:      doc.Properties["ID"],
+		Title:   title,
+		URL:     posted.URL,
+		EditURL: posted.EditURL,
+	})
+
+	return posted.EditPageURL, nil
+}
+
+// postSourceFile posts a markdown, AsciiDoc or reStructuredText file, taking
+// the title, categories and date from the metadata of its format.
+func postSourceFile(source *postSource, config *Config, category string, isDraft bool, debug bool) (string, error) {
+	doc := parseSourceDocument(source.Content, source.Format)
+	author, err := entryAuthor(config, doc.Author)
 	if err != nil {
-		return "", fmt.Errorf("failed to extract categories from org file: %v", err)
+		return "", err
 	}
 
+	title := doc.postTitle()
+	categories := append([]string{}, doc.Tags...)
 	if category != "" {
 		categories = append(categories, category)
 	}
 
-	markdown, err := convertOrgToMarkdown(absPath)
+	var updated time.Time
+	if doc.Date != "" {
+		if updated, err = parseOrgDate(doc.Date); err != nil {
+			warnf("%v; posting with the current time", err)
+		}
+	}
+
+	registry, err := loadEntryRegistry(config.entriesPath())
 	if err != nil {
-		return "", fmt.Errorf("failed to convert org to markdown: %v", err)
+		return "", err
 	}
 
-	content := removeTitleFromMarkdown(markdown)
+	opts := config.convertOptions()
+	opts.BaseDir = source.BaseDir
+	if ignored := orgOnlyOptions(opts); len(ignored) > 0 {
+		warnf("%s only apply to org files and are ignored for %s input", strings.Join(ignored, ", "), source.Format)
+	}
+	converted, err := convertSourceContent(doc, opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert %s file: %v", source.Format, err)
+	}
 
 	client := NewHatenaClient(config.HatenaID, config.APIKey, config.BlogDomain)
 	entry := BlogEntry{
-		Title:      title,
-		Content:    content,
-		Categories: categories,
-		IsDraft:    isDraft,
+		Title:       title,
+		Content:     converted,
+		ContentType: contentTypeForFormat(config.Format),
+		Categories:  categories,
+		IsDraft:     isDraft,
+		Updated:     updated,
+		Summary:     entrySummary(doc.Description, converted, config.Format, config.AutoSummary),
+		Author:      author,
+	}
+
+	posted, err := client.PostEntry(entry, debug)
+	if err != nil {
+		return "", err
 	}
 
-	return client.PostEntry(entry, debug)
+	recordPublishedEntry(config, registry, source, PublishedEntry{
+		Title:   title,
+		URL:     posted.URL,
+		EditURL: posted.EditURL,
+	})
+
+	return posted.EditPageURL, nil
+}
+
+// recordPublishedEntry saves entry, posted from source, to the registry.
+// Posts read from standard input have no file to link them by and are not
+// recorded. Failing to save is only a warning since the post is already up.
+func recordPublishedEntry(config *Config, registry *EntryRegistry, source *postSource, entry PublishedEntry) {
+	if source.Path == "" {
+		return
+	}
+	entry.File = source.Path
+	registry.record(entry)
+	if err := registry.save(config.entriesPath()); err != nil {
+		warnf("%v", err)
+	}
+}
+
+// postOrgSubtrees posts subtrees of orgFile as separate entries, either the
+// one matching selector or, if all is true, every subtree marked as a post.
+// It returns the edit URLs of the entries posted so far even on error.
+func postOrgSubtrees(orgFile, from, baseDir string, config *Config, selector string, all bool, category string, isDraft bool, debug bool) ([]string, error) {
+	source, err := readPostSource(orgFile, from, baseDir, config.Encoding, os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	if source.Format != InputOrg {
+		return nil, fmt.Errorf("-subtree and -all-subtrees need org input, not %s", source.Format)
+	}
+	// Convert everything before posting so that a broken subtree does not
+	// leave the blog with only some of the posts.
+	registry, err := loadEntryRegistry(config.entriesPath())
+	if err != nil {
+		return nil, err
+	}
+	opts := config.convertOptions()
+	opts.BaseDir = source.BaseDir
+	opts.Entries = registry
+
+	doc, err := parseOrgPostDocument(source.Content, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert org file: %v", err)
+	}
+	// Subtrees are taken from the source itself, where -subtree line
+	// numbers point
+	posts, err := extractSubtreePosts(source.Content, selector, all, doc.FileTags)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewHatenaClient(config.HatenaID, config.APIKey, config.BlogDomain)
+	var entries []BlogEntry
+	for _, post := range posts {
+		post.Title = expandOrgMacrosInValue(post.Title, post.Content, opts)
+		post.Description = expandOrgMacrosInValue(post.Description, post.Content, opts)
+		converted, err := convertOrgContent(post.Content, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert subtree %q: %v", post.Title, err)
+		}
+		content, err := insertEyecatch(converted, post.Eyecatch, source.BaseDir, client, debug)
+		if err != nil {
+			return nil, fmt.Errorf("subtree %q: %v", post.Title, err)
+		}
+
+		postAuthor := post.Author
+		if postAuthor == "" {
+			postAuthor = doc.Author
+		}
+		author, err := entryAuthor(config, postAuthor)
+		if err != nil {
+			return nil, fmt.Errorf("subtree %q: %v", post.Title, err)
+		}
+
+		categories := post.Categories
+		if category != "" {
+			categories = append(categories, category)
+		}
+
+		entries = append(entries, BlogEntry{
+			Title:       post.Title,
+			Content:     content,
+			ContentType: contentTypeForFormat(config.Format),
+			Categories:  categories,
+			IsDraft:     isDraft || post.IsDraft,
+			Updated:     post.Date,
+			CustomURL:   post.CustomURL,
+			Summary:     entrySummary(post.Description, converted, config.Format, config.AutoSummary),
+			Author:      author,
+		})
+	}
+
+	var articleURLs []string
+	for i, entry := range entries {
+		posted, err := client.PostEntry(entry, debug)
+		if err != nil {
+			return articleURLs, fmt.Errorf("failed to post %q: %v", entry.Title, err)
+		}
+		articleURLs = append(articleURLs, posted.EditPageURL)
+
+		recordPublishedEntry(config, registry, source, PublishedEntry{
+			ID:      posts[i].Heading.Properties["ID"],
+			Subtree: true,
+			Title:   entry.Title,
+			URL:     posted.URL,
+			EditURL: posted.EditURL,
+		})
+	}
+
+	return articleURLs, nil
 }
 
 func validateConfig(config *Config) error {
@@ -148,5 +429,32 @@ func validateConfig(config *Config) error {
 	if config.BlogDomain == "" {
 		return fmt.Errorf("blog domain is required")
 	}
+	if err := validateFormat(config.Format); err != nil {
+		return err
+	}
+	if err := validateMath(config.Math); err != nil {
+		return err
+	}
+	if err := validateFootnotes(config.Footnotes); err != nil {
+		return err
+	}
+	if err := validateEmbedDomains(config.EmbedDomains); err != nil {
+		return err
+	}
+	if err := validateEncoding(config.Encoding); err != nil {
+		return err
+	}
+	if config.HeadingOffset < 0 || config.HeadingOffset >= maxHatenaHeadingLevel {
+		return fmt.Errorf("heading offset must be between 0 and %d: %d", maxHatenaHeadingLevel-1, config.HeadingOffset)
+	}
+	if config.AutoSummary < 0 {
+		return fmt.Errorf("auto summary length must not be negative: %d", config.AutoSummary)
+	}
+	if err := validateMembers(config.Members); err != nil {
+		return err
+	}
+	if _, err := entryAuthor(config, ""); err != nil {
+		return err
+	}
 	return nil
 }
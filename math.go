@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	MathTex     = "tex"
+	MathMathJax = "mathjax"
+	MathNone    = "none"
+)
+
+var (
+	latexBeginRe = regexp.MustCompile(`(?m)^[ \t]*\\begin\{([a-zA-Z]+\*?)\}`)
+	mathSpaceRe  = regexp.MustCompile(`\s*\n\s*`)
+)
+
+func validateMath(math string) error {
+	switch math {
+	case "", MathTex, MathMathJax, MathNone:
+		return nil
+	}
+	return fmt.Errorf("unsupported math mode: %s (expected %q, %q or %q)", math, MathTex, MathMathJax, MathNone)
+}
+
+// convertOrgMath replaces LaTeX fragments ($...$, \(...\), \[...\], $$...$$
+// and \begin{...} environments) outside of blocks with Hatena's [tex:...]
+// notation, or MathJax delimiters, as inline HTML export snippets so that
+// pandoc does not escape them. format is the output format the snippets
+// must survive.
+func convertOrgMath(orgContent, mode, format string) string {
+	if mode == MathNone {
+		return orgContent
+	}
+
+	lines := strings.Split(orgContent, "\n")
+	var result []string
+	var region []string
+	flush := func() {
+		if len(region) > 0 {
+			result = append(result, convertMathInText(strings.Join(region, "\n"), mode, format))
+			region = nil
+		}
+	}
+
+	inBlock := false
+	for _, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			flush()
+			result = append(result, line)
+			continue
+		}
+		region = append(region, line)
+	}
+	flush()
+
+	return strings.Join(result, "\n")
+}
+
+// convertMathInText converts the math in a run of lines without blocks.
+func convertMathInText(text, mode, format string) string {
+	runes := []rune(text)
+	var b strings.Builder
+
+	for i := 0; i < len(runes); i++ {
+		if skip := orgInlineObjectEnd(runes, i); skip > i {
+			b.WriteString(string(runes[i:skip]))
+			i = skip - 1
+			continue
+		}
+		if skip := orgVerbatimEnd(runes, i); skip > i {
+			b.WriteString(string(runes[i:skip]))
+			i = skip - 1
+			continue
+		}
+
+		body, end, display := findLatexFragment(runes, i)
+		if end < 0 {
+			b.WriteRune(runes[i])
+			continue
+		}
+		b.WriteString(renderMath(body, display, mode, format))
+		i = end - 1
+	}
+	return b.String()
+}
+
+// orgVerbatimEnd returns the index just past =verbatim= or ~code~ starting
+// at i, whose contents are never math, or i if there is none.
+func orgVerbatimEnd(runes []rune, i int) int {
+	if runes[i] != '=' && runes[i] != '~' {
+		return i
+	}
+	end, _ := findEmphasisEnd(runes, i)
+	if end < 0 || strings.Contains(string(runes[i:end]), "\n\n") {
+		return i
+	}
+	return end + 1
+}
+
+// findLatexFragment looks for a LaTeX fragment starting at i. It returns the
+// math inside the delimiters, the index just past the fragment and whether
+// it is display math, or an end of -1 when there is none.
+func findLatexFragment(runes []rune, i int) (body string, end int, display bool) {
+	rest := string(runes[i:])
+
+	if atLineStart(runes, i) {
+		if m := latexBeginRe.FindStringSubmatchIndex(rest); m != nil && m[0] == 0 {
+			env := rest[m[2]:m[3]]
+			closing := `\end{` + env + `}`
+			if j := strings.Index(rest, closing); j >= 0 {
+				fragment := strings.TrimSpace(rest[:j+len(closing)])
+				return latexEnvironmentBody(env, fragment), i + len([]rune(rest[:j+len(closing)])), true
+			}
+		}
+	}
+
+	for _, d := range []struct {
+		open, close string
+		display     bool
+	}{
+		{`\[`, `\]`, true},
+		{`$$`, `$$`, true},
+		{`\(`, `\)`, false},
+	} {
+		if !strings.HasPrefix(rest, d.open) {
+			continue
+		}
+		j := strings.Index(rest[len(d.open):], d.close)
+		if j < 0 {
+			return "", -1, false
+		}
+		body = rest[len(d.open) : len(d.open)+j]
+		return body, i + len([]rune(rest[:len(d.open)+j+len(d.close)])), d.display
+	}
+
+	if runes[i] == '$' {
+		if end := findDollarMathEnd(runes, i); end > 0 {
+			return string(runes[i+1 : end]), end + 1, false
+		}
+	}
+	return "", -1, false
+}
+
+func atLineStart(runes []rune, i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		if runes[j] == '\n' {
+			return true
+		}
+		if runes[j] != ' ' && runes[j] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// findDollarMathEnd returns the index of the closing $ of $...$ math opened
+// at start, following org's rules so that prices like $5 are left alone, or
+// -1 if there is none. Unlike org, CJK characters may touch the dollars, as
+// in 日本語$y$の, since CJK text has no spaces between words.
+func findDollarMathEnd(runes []rune, start int) int {
+	if start > 0 && (runes[start-1] == '$' || isWordRune(runes[start-1])) {
+		return -1
+	}
+	if start+1 >= len(runes) || strings.ContainsRune(" \t\n.,;$", runes[start+1]) {
+		return -1
+	}
+
+	for end := start + 1; end < len(runes); end++ {
+		if runes[end] == '\n' && end+1 < len(runes) && runes[end+1] == '\n' {
+			// Math does not span paragraphs
+			return -1
+		}
+		if runes[end] != '$' {
+			continue
+		}
+		// The math may not contain a $ itself, so this one has to close it
+		if strings.ContainsRune(" \t\n.,", runes[end-1]) {
+			return -1
+		}
+		if end+1 < len(runes) && (isWordRune(runes[end+1]) || runes[end+1] == '$') {
+			return -1
+		}
+		return end
+	}
+	return -1
+}
+
+// latexEnvironmentBody turns an environment into math that can stand on its
+// own in display mode.
+func latexEnvironmentBody(env, fragment string) string {
+	switch env {
+	case "equation", "equation*", "displaymath", "math":
+		body := strings.TrimPrefix(fragment, `\begin{`+env+`}`)
+		return strings.TrimSuffix(body, `\end{`+env+`}`)
+	case "align", "align*", "eqnarray", "eqnarray*":
+		body := strings.TrimPrefix(fragment, `\begin{`+env+`}`)
+		body = strings.TrimSuffix(body, `\end{`+env+`}`)
+		return `\begin{aligned}` + body + `\end{aligned}`
+	}
+	return fragment
+}
+
+func renderMath(body string, display bool, mode, format string) string {
+	body = strings.TrimSpace(mathSpaceRe.ReplaceAllString(body, " "))
+
+	var rendered string
+	switch mode {
+	case MathMathJax:
+		if display {
+			rendered = `\[` + body + `\]`
+		} else {
+			rendered = `\(` + body + `\)`
+		}
+		if format != FormatHTML {
+			rendered = escapeMathForMarkdown(rendered)
+		}
+	default:
+		if display {
+			body = `\displaystyle ` + body
+		}
+		if format == FormatHTML {
+			rendered = "[tex:" + strings.ReplaceAll(body, "]", `\]`) + "]"
+		} else {
+			rendered = "[tex:" + escapeMathForMarkdown(body) + "]"
+		}
+	}
+
+	snippet := "@@html:" + html.EscapeString(rendered) + "@@"
+	if display {
+		// Display math is a paragraph of its own
+		return "\n\n" + snippet + "\n\n"
+	}
+	return snippet
+}
+
+// escapeMathForMarkdown escapes math so that it reaches MathJax unchanged
+// after Hatena's markdown processing: backslashes are doubled and the
+// characters markdown treats specially are backslash-escaped.
+func escapeMathForMarkdown(math string) string {
+	var b strings.Builder
+	for _, r := range math {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '_', '^', '*', ']':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import "testing"
+
+func TestConvertOrgMath(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		mode     string
+		format   string
+		expected string
+	}{
+		{
+			name:     "inline dollar math",
+			input:    "The area is $\\pi r^2$.",
+			expected: "The area is @@html:[tex:\\\\pi r\\^2]@@.",
+		},
+		{
+			name:     "inline paren math with subscript",
+			input:    "円周率\\(x_{i}\\)です",
+			expected: "円周率@@html:[tex:x\\_{i}]@@です",
+		},
+		{
+			name:     "braces and brackets escaped",
+			input:    "$\\{a\\} \\cup [0, 1]$",
+			expected: "@@html:[tex:\\\\{a\\\\} \\\\cup [0, 1\\]]@@",
+		},
+		{
+			name:     "display math",
+			input:    "Before\n\\[\n  E = mc^2\n\\]\nAfter",
+			expected: "Before\n\n\n@@html:[tex:\\\\displaystyle E = mc\\^2]@@\n\n\nAfter",
+		},
+		{
+			name:     "equation environment",
+			input:    "\\begin{equation}\na < b\n\\end{equation}",
+			expected: "\n\n@@html:[tex:\\\\displaystyle a &lt; b]@@\n\n",
+		},
+		{
+			name:     "align environment",
+			input:    "\\begin{align*}\nx &= 1 \\\\\ny &= 2\n\\end{align*}",
+			expected: "\n\n@@html:[tex:\\\\displaystyle \\\\begin{aligned} x &amp;= 1 \\\\\\\\ y &amp;= 2 \\\\end{aligned}]@@\n\n",
+		},
+		{
+			name:     "html format only escapes brackets",
+			input:    "$a_1 [b]$",
+			format:   FormatHTML,
+			expected: "@@html:[tex:a_1 [b\\]]@@",
+		},
+		{
+			name:     "mathjax in markdown",
+			input:    "$x_1$",
+			mode:     MathMathJax,
+			expected: "@@html:\\\\(x\\_1\\\\)@@",
+		},
+		{
+			name:     "mathjax in html",
+			input:    "\\[x_1\\]",
+			mode:     MathMathJax,
+			format:   FormatHTML,
+			expected: "\n\n@@html:\\[x_1\\]@@\n\n",
+		},
+		{
+			name:     "none leaves math alone",
+			input:    "$x_1$",
+			mode:     MathNone,
+			expected: "$x_1$",
+		},
+		{
+			name:     "prices are not math",
+			input:    "It costs $5 and $10.",
+			expected: "It costs $5 and $10.",
+		},
+		{
+			name:     "dollar math next to CJK text",
+			input:    "日本語$y$の式とa$b$c",
+			expected: "日本語@@html:[tex:y]@@の式とa$b$c",
+		},
+		{
+			name:     "verbatim and code untouched",
+			input:    "Run =echo $a$= or ~$b$~, then $c$.",
+			expected: "Run =echo $a$= or ~$b$~, then @@html:[tex:c]@@.",
+		},
+		{
+			name:     "src blocks and links untouched",
+			input:    "#+begin_src sh\necho $HOME $PATH\n#+end_src\n[[https://example.com/$a$]]",
+			expected: "#+begin_src sh\necho $HOME $PATH\n#+end_src\n[[https://example.com/$a$]]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := tt.mode
+			if mode == "" {
+				mode = MathTex
+			}
+			result := convertOrgMath(tt.input, mode, tt.format)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidateMath(t *testing.T) {
+	for _, mode := range []string{"", MathTex, MathMathJax, MathNone} {
+		if err := validateMath(mode); err != nil {
+			t.Errorf("validateMath(%q) returned error: %v", mode, err)
+		}
+	}
+	if err := validateMath("katex"); err == nil {
+		t.Error("Expected error for unsupported math mode")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// orgMoreProperty on a headline puts the "read more" fold before it.
+const orgMoreProperty = "EXPORT_HATENA_MORE"
+
+var orgMoreRe = regexp.MustCompile(`(?i)^\s*(?:#\+more:?|#[ \t]+more)[ \t]*$`)
+
+// hatenaMore is the marker Hatena folds the entry at, written as an HTML
+// export block because pandoc drops org comments.
+var hatenaMore = []string{"#+begin_export html", "<!-- more -->", "#+end_export"}
+
+// insertOrgMoreMarker replaces a "#+more" keyword or "# more" comment line,
+// or puts a marker before a headline with a non-nil :EXPORT_HATENA_MORE:
+// property, with Hatena's <!-- more --> fold. Only the first fold point is
+// used; later ones are removed with a warning.
+func insertOrgMoreMarker(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	headingStarts := map[int]bool{}
+	for _, h := range parseOrgHeadings(lines) {
+		if value, ok := h.Properties[orgMoreProperty]; ok && value != "nil" {
+			headingStarts[h.start] = true
+		}
+	}
+
+	var result []string
+	inserted := false
+	inBlock := false
+	for i, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			result = append(result, line)
+			continue
+		}
+		isMarker := orgMoreRe.MatchString(line)
+		if !isMarker && !headingStarts[i] {
+			result = append(result, line)
+			continue
+		}
+
+		if inserted {
+			warnf("ignoring fold point at line %d: only the first one is used", i+1)
+		} else {
+			result = append(result, hatenaMore...)
+			inserted = true
+		}
+		if !isMarker {
+			result = append(result, line)
+		}
+	}
+
+	return strings.Join(result, "\n")
+}
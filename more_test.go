@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestInsertOrgMoreMarker(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "keyword",
+			input:    "lead\n#+more\nrest",
+			expected: "lead\n#+begin_export html\n<!-- more -->\n#+end_export\nrest",
+		},
+		{
+			name:     "comment",
+			input:    "lead\n# more\nrest",
+			expected: "lead\n#+begin_export html\n<!-- more -->\n#+end_export\nrest",
+		},
+		{
+			name:     "heading property",
+			input:    "lead\n* Details\n:PROPERTIES:\n:EXPORT_HATENA_MORE: t\n:END:\nrest",
+			expected: "lead\n#+begin_export html\n<!-- more -->\n#+end_export\n* Details\n:PROPERTIES:\n:EXPORT_HATENA_MORE: t\n:END:\nrest",
+		},
+		{
+			name:     "only the first fold point",
+			input:    "a\n#+MORE:\nb\n# more\nc",
+			expected: "a\n#+begin_export html\n<!-- more -->\n#+end_export\nb\nc",
+		},
+		{
+			name:     "other comments and blocks untouched",
+			input:    "# more or less\n#+begin_example\n# more\n#+end_example",
+			expected: "# more or less\n#+begin_example\n# more\n#+end_example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := insertOrgMoreMarker(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
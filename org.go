@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	orgHeadingRe      = regexp.MustCompile(`^(\*+)[ \t]+(.*?)[ \t]*$`)
+	orgHeadingTagsRe  = regexp.MustCompile(`[ \t]+(:[^\s:]+(?::[^\s:]+)*:)$`)
+	orgPriorityRe     = regexp.MustCompile(`^\[#[A-Za-z0-9]\][ \t]*`)
+	orgPlanningRe     = regexp.MustCompile(`^\s*(?:SCHEDULED|DEADLINE|CLOSED):`)
+	orgPropertyLineRe = regexp.MustCompile(`^\s*:([^:\s]+):(?:[ \t]+(.*?))?[ \t]*$`)
+	orgKeywordRe      = regexp.MustCompile(`^\s*#\+([^:\s]+):[ \t]*(.*?)[ \t]*$`)
+	orgTimestampRe    = regexp.MustCompile(`^[<\[](\d{4}-\d{2}-\d{2})(?:\s+[^\s\d>\]]+)?(?:\s+(\d{1,2}:\d{2}))?[^>\]]*[>\]]$`)
+)
+
+// orgTodoKeywords are the default TODO keywords org recognizes at the start
+// of a headline.
+var orgTodoKeywords = []string{"TODO", "DONE"}
+
+// orgHeading is a headline together with the extent of its subtree.
+type orgHeading struct {
+	Level int
+	// Title is the headline text without TODO keyword, priority and tags.
+	Title      string
+	Todo       string
+	Tags       []string
+	Properties map[string]string
+	// Line is the 1-based line number of the headline.
+	Line   int
+	Parent *orgHeading
+
+	// start is the index of the headline in the line slice, bodyStart the
+	// first line after the planning line and property drawer, and end the
+	// index just past the last line of the subtree.
+	start     int
+	bodyStart int
+	end       int
+}
+
+func isOrgHeading(line string) bool {
+	return orgHeadingRe.MatchString(line)
+}
+
+// parseOrgHeadline splits a headline into its level, TODO keyword, title
+// and tags.
+func parseOrgHeadline(line string) (level int, todo, title string, tags []string) {
+	m := orgHeadingRe.FindStringSubmatch(line)
+	if m == nil {
+		return 0, "", "", nil
+	}
+	level = len(m[1])
+	text := m[2]
+
+	if tm := orgHeadingTagsRe.FindStringSubmatchIndex(text); tm != nil {
+		tags = splitOrgTags(text[tm[2]:tm[3]])
+		text = text[:tm[0]]
+	} else if orgHeadingTagsRe.MatchString(" " + text) {
+		// A headline consisting only of tags
+		tags = splitOrgTags(text)
+		text = ""
+	}
+
+	for _, keyword := range orgTodoKeywords {
+		if text == keyword || strings.HasPrefix(text, keyword+" ") {
+			todo = keyword
+			text = strings.TrimSpace(text[len(keyword):])
+			break
+		}
+	}
+	text = orgPriorityRe.ReplaceAllString(text, "")
+
+	return level, todo, strings.TrimSpace(text), tags
+}
+
+func splitOrgTags(tagsString string) []string {
+	var tags []string
+	parts := strings.FieldsFunc(tagsString, func(c rune) bool {
+		return c == ' ' || c == '\t' || c == ':'
+	})
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			tags = append(tags, part)
+		}
+	}
+	return tags
+}
+
+// parseOrgHeadings returns every headline in lines in document order.
+func parseOrgHeadings(lines []string) []*orgHeading {
+	var headings []*orgHeading
+	var stack []*orgHeading
+	inBlock := false
+
+	for i, line := range lines {
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		if !isOrgHeading(line) {
+			continue
+		}
+
+		level, todo, title, tags := parseOrgHeadline(line)
+		h := &orgHeading{
+			Level:      level,
+			Title:      title,
+			Todo:       todo,
+			Tags:       tags,
+			Properties: map[string]string{},
+			Line:       i + 1,
+			start:      i,
+			end:        len(lines),
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].Level >= level {
+			stack[len(stack)-1].end = i
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			h.Parent = stack[len(stack)-1]
+		}
+		stack = append(stack, h)
+
+		h.bodyStart = parseOrgSectionHeader(lines, i+1, h.Properties)
+		headings = append(headings, h)
+	}
+
+	return headings
+}
+
+// parseOrgSectionHeader reads the optional planning line and property drawer
+// that directly follow a headline, storing properties into props. It
+// returns the index of the first line of the section body.
+func parseOrgSectionHeader(lines []string, i int, props map[string]string) int {
+	if i < len(lines) && orgPlanningRe.MatchString(lines[i]) {
+		i++
+	}
+	if i >= len(lines) || !strings.EqualFold(strings.TrimSpace(lines[i]), ":PROPERTIES:") {
+		return i
+	}
+
+	for j := i + 1; j < len(lines); j++ {
+		if strings.EqualFold(strings.TrimSpace(lines[j]), ":END:") {
+			return j + 1
+		}
+		if m := orgPropertyLineRe.FindStringSubmatch(lines[j]); m != nil {
+			props[strings.ToUpper(m[1])] = m[2]
+		}
+	}
+
+	// An unterminated drawer is not a drawer
+	return i
+}
+
+// isOrgBlockBoundary tracks #+begin_/#+end_ blocks. It reports whether line
+// opens or closes a block and updates inBlock accordingly.
+func isOrgBlockBoundary(line string, inBlock *bool) bool {
+	trimmed := strings.ToLower(strings.TrimSpace(line))
+	if !*inBlock && strings.HasPrefix(trimmed, "#+begin_") {
+		*inBlock = true
+		return true
+	}
+	if *inBlock && strings.HasPrefix(trimmed, "#+end_") {
+		*inBlock = false
+		return true
+	}
+	return false
+}
+
+// inheritedTags returns the tags of h and all of its ancestors, outermost
+// first, without duplicates.
+func (h *orgHeading) inheritedTags() []string {
+	var chain []*orgHeading
+	for cur := h; cur != nil; cur = cur.Parent {
+		chain = append([]*orgHeading{cur}, chain...)
+	}
+
+	seen := map[string]bool{}
+	var tags []string
+	for _, cur := range chain {
+		for _, tag := range cur.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+func (h *orgHeading) hasTag(tag string) bool {
+	for _, t := range h.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// orgFileKeywordLines returns the #+KEYWORD lines that appear before the
+// first headline.
+func orgFileKeywordLines(lines []string) []string {
+	var keywords []string
+	for _, line := range lines {
+		if isOrgHeading(line) {
+			break
+		}
+		if orgKeywordRe.MatchString(line) {
+			keywords = append(keywords, line)
+		}
+	}
+	return keywords
+}
+
+// shiftOrgHeadings changes the level of every headline in lines by delta,
+// never going below level 1.
+func shiftOrgHeadings(lines []string, delta int) []string {
+	result := make([]string, len(lines))
+	inBlock := false
+	for i, line := range lines {
+		result[i] = line
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		m := orgHeadingRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		level := len(m[1]) + delta
+		if level < 1 {
+			level = 1
+		}
+		result[i] = strings.Repeat("*", level) + line[len(m[1]):]
+	}
+	return result
+}
+
+// parseOrgDate parses an org timestamp such as <2024-01-02 Tue 10:30> or a
+// plain date or RFC 3339 string in the local time zone.
+func parseOrgDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	date, clock := value, ""
+	if m := orgTimestampRe.FindStringSubmatch(value); m != nil {
+		date, clock = m[1], m[2]
+	} else if fields := strings.Fields(value); len(fields) == 2 {
+		date, clock = fields[0], fields[1]
+	}
+
+	if clock == "" {
+		t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date: %s", value)
+		}
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %s", value)
+	}
+	return t, nil
+}
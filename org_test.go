@@ -0,0 +1,144 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOrgHeadline(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		expectedLevel int
+		expectedTodo  string
+		expectedTitle string
+		expectedTags  []string
+	}{
+		{
+			name:          "plain headline",
+			line:          "* Simple title",
+			expectedLevel: 1,
+			expectedTitle: "Simple title",
+		},
+		{
+			name:          "headline with tags",
+			line:          "** Post title   :emacs:go:",
+			expectedLevel: 2,
+			expectedTitle: "Post title",
+			expectedTags:  []string{"emacs", "go"},
+		},
+		{
+			name:          "todo and priority",
+			line:          "*** TODO [#A] Write article :blog:",
+			expectedLevel: 3,
+			expectedTodo:  "TODO",
+			expectedTitle: "Write article",
+			expectedTags:  []string{"blog"},
+		},
+		{
+			name:          "japanese title",
+			line:          "* はてなブログへの投稿",
+			expectedLevel: 1,
+			expectedTitle: "はてなブログへの投稿",
+		},
+		{
+			name:          "not a headline",
+			line:          "*bold* text",
+			expectedLevel: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, todo, title, tags := parseOrgHeadline(tt.line)
+			if level != tt.expectedLevel {
+				t.Errorf("Expected level %d, got %d", tt.expectedLevel, level)
+			}
+			if todo != tt.expectedTodo {
+				t.Errorf("Expected todo %q, got %q", tt.expectedTodo, todo)
+			}
+			if title != tt.expectedTitle {
+				t.Errorf("Expected title %q, got %q", tt.expectedTitle, title)
+			}
+			if !reflect.DeepEqual(tags, tt.expectedTags) {
+				t.Errorf("Expected tags %v, got %v", tt.expectedTags, tags)
+			}
+		})
+	}
+}
+
+func TestParseOrgHeadings(t *testing.T) {
+	content := `#+title: Blog
+* Parent :a:
+:PROPERTIES:
+:ID:       1234
+:END:
+text
+#+begin_src org
+* not a heading
+#+end_src
+** Child :b:
+* Second`
+	headings := parseOrgHeadings(strings.Split(content, "\n"))
+
+	if len(headings) != 3 {
+		t.Fatalf("Expected 3 headings, got %d", len(headings))
+	}
+	parent, child, second := headings[0], headings[1], headings[2]
+
+	if parent.Properties["ID"] != "1234" {
+		t.Errorf("Expected ID property 1234, got %q", parent.Properties["ID"])
+	}
+	if parent.Line != 2 || parent.bodyStart != 5 || parent.end != 10 {
+		t.Errorf("Unexpected parent extent: line=%d bodyStart=%d end=%d", parent.Line, parent.bodyStart, parent.end)
+	}
+	if child.Parent != parent {
+		t.Error("Expected child to have parent as its parent")
+	}
+	if !reflect.DeepEqual(child.inheritedTags(), []string{"a", "b"}) {
+		t.Errorf("Expected inherited tags [a b], got %v", child.inheritedTags())
+	}
+	if second.Parent != nil {
+		t.Error("Expected second top-level heading to have no parent")
+	}
+}
+
+func TestShiftOrgHeadings(t *testing.T) {
+	lines := []string{"** Child", "text", "*** Grandchild", "#+begin_example", "** literal", "#+end_example"}
+	expected := []string{"* Child", "text", "** Grandchild", "#+begin_example", "** literal", "#+end_example"}
+
+	result := shiftOrgHeadings(lines, -1)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestParseOrgDate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"<2024-01-02 Tue>", time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{"[2024-01-02 火 10:30]", time.Date(2024, 1, 2, 10, 30, 0, 0, time.Local)},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{"2024-01-02 08:05", time.Date(2024, 1, 2, 8, 5, 0, 0, time.Local)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := parseOrgDate(tt.input)
+			if err != nil {
+				t.Fatalf("parseOrgDate failed: %v", err)
+			}
+			if !result.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+
+	if _, err := parseOrgDate("yesterday"); err == nil {
+		t.Error("Expected error for invalid date")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// orgHatenaBackend is the export backend name for raw Hatena notation such
+// as [:contents], written as #+begin_export hatena or @@hatena:...@@.
+const orgHatenaBackend = "hatena"
+
+var (
+	orgHatenaExportBeginRe = regexp.MustCompile(`(?i)^(\s*#\+begin_export)[ \t]+hatena[ \t]*$`)
+	orgHatenaSnippetRe     = regexp.MustCompile(`(?i)@@hatena:`)
+	rawBlockFenceRe        = regexp.MustCompile("^(`{3,})\\{=([A-Za-z0-9_-]+)\\}[ \t]*$")
+	codeFenceRe            = regexp.MustCompile("^(`{3,}|~{3,})")
+	rawInlineFormatRe      = regexp.MustCompile(`^\{=([A-Za-z0-9_-]+)\}`)
+)
+
+// mapHatenaExports turns hatena export blocks and snippets into html ones,
+// which pandoc passes through in both output formats.
+func mapHatenaExports(orgContent string) string {
+	lines := strings.Split(orgContent, "\n")
+	inBlock := false
+	for i, line := range lines {
+		if m := orgHatenaExportBeginRe.FindStringSubmatch(line); m != nil && !inBlock {
+			lines[i] = m[1] + " html"
+			inBlock = true
+			continue
+		}
+		if isOrgBlockBoundary(line, &inBlock) || inBlock {
+			continue
+		}
+		lines[i] = orgHatenaSnippetRe.ReplaceAllString(line, "@@html:")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isPassthroughFormat reports whether raw content for format is published
+// as it is in markdown output.
+func isPassthroughFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "html", "markdown", orgHatenaBackend:
+		return true
+	}
+	return false
+}
+
+// unwrapRawExports replaces the ```{=html} fences and `...`{=html} inline
+// code pandoc writes for raw content with the content itself, so that HTML,
+// markdown and Hatena notation reach Hatena verbatim. Raw content for other
+// backends, such as latex, is dropped.
+func unwrapRawExports(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	var result []string
+	rawFence, rawFormat, codeFence := "", "", ""
+
+	for _, line := range lines {
+		switch {
+		case rawFence != "":
+			if strings.TrimSpace(line) == rawFence {
+				rawFence = ""
+				continue
+			}
+			if isPassthroughFormat(rawFormat) {
+				result = append(result, line)
+			}
+			continue
+		case codeFence != "":
+			if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, codeFence) && strings.Trim(trimmed, codeFence[:1]) == "" {
+				codeFence = ""
+			}
+			result = append(result, line)
+			continue
+		}
+
+		if m := rawBlockFenceRe.FindStringSubmatch(line); m != nil {
+			rawFence, rawFormat = m[1], m[2]
+			continue
+		}
+		if m := codeFenceRe.FindStringSubmatch(line); m != nil {
+			codeFence = m[1]
+			result = append(result, line)
+			continue
+		}
+		result = append(result, unwrapRawInline(line))
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// unwrapRawInline unwraps `...`{=format} raw inline content, leaving other
+// code spans alone.
+func unwrapRawInline(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		if line[i] != '`' {
+			b.WriteByte(line[i])
+			i++
+			continue
+		}
+		open := backtickRun(line, i)
+		// The code span ends at the next run of as many backticks
+		end := -1
+		for j := i + open; j < len(line); {
+			if line[j] != '`' {
+				j++
+				continue
+			}
+			run := backtickRun(line, j)
+			if run == open {
+				end = j
+				break
+			}
+			j += run
+		}
+		if end < 0 {
+			b.WriteString(line[i:])
+			break
+		}
+
+		next := end + open
+		m := rawInlineFormatRe.FindStringSubmatch(line[next:])
+		if m == nil {
+			b.WriteString(line[i:next])
+			i = next
+			continue
+		}
+		if isPassthroughFormat(m[1]) {
+			content := line[i+open : end]
+			if open > 1 {
+				// Pandoc pads content that starts or ends with a backtick
+				content = strings.TrimPrefix(strings.TrimSuffix(content, " "), " ")
+			}
+			b.WriteString(content)
+		}
+		i = next + len(m[0])
+	}
+	return b.String()
+}
+
+func backtickRun(line string, i int) int {
+	n := 0
+	for i+n < len(line) && line[i+n] == '`' {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestMapHatenaExports(t *testing.T) {
+	input := "#+begin_export hatena\n[:contents]\n#+end_export\n@@hatena:[google:画像]@@ and @@html:<br>@@\n#+begin_example\n@@hatena:x@@\n#+end_example"
+	expected := "#+begin_export html\n[:contents]\n#+end_export\n@@html:[google:画像]@@ and @@html:<br>@@\n#+begin_example\n@@hatena:x@@\n#+end_example"
+
+	if result := mapHatenaExports(input); result != expected {
+		t.Errorf("Expected:\n%q\nGot:\n%q", expected, result)
+	}
+}
+
+func TestUnwrapRawExports(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "html block",
+			input:    "before\n\n```{=html}\n<div class=\"note\">\n```\n\nafter",
+			expected: "before\n\n<div class=\"note\">\n\nafter",
+		},
+		{
+			name:     "markdown and hatena blocks",
+			input:    "```{=markdown}\n**bold**\n```\n````{=hatena}\n[:contents]\n````",
+			expected: "**bold**\n[:contents]",
+		},
+		{
+			name:     "other backends dropped",
+			input:    "a\n```{=latex}\n\\newpage\n```\nb",
+			expected: "a\nb",
+		},
+		{
+			name:     "inline snippets",
+			input:    "Press `<kbd>C-x</kbd>`{=html} now`\\LaTeX`{=latex}, keep `code`.",
+			expected: "Press <kbd>C-x</kbd> now, keep `code`.",
+		},
+		{
+			name:     "inline snippet with backtick",
+			input:    "`` a`b ``{=html} and `x`",
+			expected: "a`b and `x`",
+		},
+		{
+			name:     "code blocks untouched",
+			input:    "```org\n```{=html}\n`x`{=html}\n```",
+			expected: "```org\n```{=html}\n`x`{=html}\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := unwrapRawExports(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	orgBlockBeginRe = regexp.MustCompile(`(?i)^\s*#\+begin_(\S+)(?:[ \t]+(.*?))?[ \t]*$`)
+	orgBlockEndRe   = regexp.MustCompile(`(?i)^\s*#\+end_(\S+)[ \t]*$`)
+)
+
+// SpecialBlockStyle is the HTML a special block such as #+begin_note is
+// rendered as.
+type SpecialBlockStyle struct {
+	// Class is the class of the enclosing element; the block name is used
+	// when it is empty.
+	Class string `json:"class,omitempty"`
+	// Title is shown at the top of the block unless the block gives its own
+	// after #+begin_name.
+	Title string `json:"title,omitempty"`
+	// Collapsible renders the block as <details> with the title as its
+	// <summary>.
+	Collapsible bool `json:"collapsible,omitempty"`
+}
+
+// defaultSpecialBlocks are the special blocks rendered without any
+// configuration.
+var defaultSpecialBlocks = map[string]SpecialBlockStyle{
+	"note":    {Class: "note", Title: "メモ"},
+	"tip":     {Class: "tip", Title: "ヒント"},
+	"warning": {Class: "warning", Title: "注意"},
+	"details": {Class: "details", Title: "詳細", Collapsible: true},
+}
+
+// processOrgSpecialBlocks replaces the begin and end lines of special blocks
+// with HTML export blocks according to styles, which take precedence over
+// defaultSpecialBlocks. The contents stay org so that they are converted as
+// usual. Blocks without a style are left to pandoc.
+func processOrgSpecialBlocks(orgContent string, styles map[string]SpecialBlockStyle) string {
+	lines := strings.Split(orgContent, "\n")
+	var result []string
+	// closing holds the HTML end tags of the open special blocks
+	var closing []struct{ name, tag string }
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if len(closing) > 0 {
+			if m := orgBlockEndRe.FindStringSubmatch(line); m != nil && strings.EqualFold(m[1], closing[len(closing)-1].name) {
+				result = append(result, "#+begin_export html", closing[len(closing)-1].tag, "#+end_export")
+				closing = closing[:len(closing)-1]
+				continue
+			}
+		}
+
+		m := orgBlockBeginRe.FindStringSubmatch(line)
+		if m == nil {
+			result = append(result, line)
+			continue
+		}
+		name := strings.ToLower(m[1])
+		style, ok := lookupSpecialBlockStyle(name, styles)
+		if !ok {
+			// Copy other blocks verbatim
+			end := findOrgBlockEnd(lines, i)
+			result = append(result, lines[i:end+1]...)
+			i = end
+			continue
+		}
+
+		title := style.Title
+		if m[2] != "" {
+			title = m[2]
+		}
+		class := style.Class
+		if class == "" {
+			class = name
+		}
+
+		var open, tag string
+		if style.Collapsible {
+			open = `<details class="` + html.EscapeString(class) + `"><summary>` + html.EscapeString(title) + `</summary>`
+			tag = "</details>"
+		} else {
+			open = `<div class="` + html.EscapeString(class) + `">`
+			if title != "" {
+				open += `<p class="` + html.EscapeString(class) + `-title">` + html.EscapeString(title) + `</p>`
+			}
+			tag = "</div>"
+		}
+		result = append(result, "#+begin_export html", open, "#+end_export")
+		closing = append(closing, struct{ name, tag string }{name, tag})
+	}
+
+	// Unterminated blocks are closed at the end of the document
+	for len(closing) > 0 {
+		result = append(result, "#+begin_export html", closing[len(closing)-1].tag, "#+end_export")
+		closing = closing[:len(closing)-1]
+	}
+
+	return strings.Join(result, "\n")
+}
+
+func lookupSpecialBlockStyle(name string, styles map[string]SpecialBlockStyle) (SpecialBlockStyle, bool) {
+	for key, style := range styles {
+		if strings.EqualFold(key, name) {
+			return style, true
+		}
+	}
+	style, ok := defaultSpecialBlocks[name]
+	return style, ok
+}
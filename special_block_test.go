@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestProcessOrgSpecialBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		styles   map[string]SpecialBlockStyle
+		expected string
+	}{
+		{
+			name:     "default note",
+			input:    "#+begin_note\n本文\n#+end_note",
+			expected: "#+begin_export html\n<div class=\"note\"><p class=\"note-title\">メモ</p>\n#+end_export\n本文\n#+begin_export html\n</div>\n#+end_export",
+		},
+		{
+			name:     "title from block arguments",
+			input:    "#+BEGIN_WARNING 破壊的な変更\ntext\n#+END_WARNING",
+			expected: "#+begin_export html\n<div class=\"warning\"><p class=\"warning-title\">破壊的な変更</p>\n#+end_export\ntext\n#+begin_export html\n</div>\n#+end_export",
+		},
+		{
+			name:     "collapsible details",
+			input:    "#+begin_details ログ\n#+begin_src sh\nls\n#+end_src\n#+end_details",
+			expected: "#+begin_export html\n<details class=\"details\"><summary>ログ</summary>\n#+end_export\n#+begin_src sh\nls\n#+end_src\n#+begin_export html\n</details>\n#+end_export",
+		},
+		{
+			name:  "configured style overrides default and adds blocks",
+			input: "#+begin_note\na\n#+end_note\n#+begin_aside\nb\n#+end_aside",
+			styles: map[string]SpecialBlockStyle{
+				"note":  {Class: "callout callout-note"},
+				"aside": {Title: "余談", Collapsible: true},
+			},
+			expected: "#+begin_export html\n<div class=\"callout callout-note\">\n#+end_export\na\n#+begin_export html\n</div>\n#+end_export\n#+begin_export html\n<details class=\"aside\"><summary>余談</summary>\n#+end_export\nb\n#+begin_export html\n</details>\n#+end_export",
+		},
+		{
+			name:     "nested special blocks",
+			input:    "#+begin_details\n#+begin_tip\nx\n#+end_tip\n#+end_details",
+			expected: "#+begin_export html\n<details class=\"details\"><summary>詳細</summary>\n#+end_export\n#+begin_export html\n<div class=\"tip\"><p class=\"tip-title\">ヒント</p>\n#+end_export\nx\n#+begin_export html\n</div>\n#+end_export\n#+begin_export html\n</details>\n#+end_export",
+		},
+		{
+			name:     "unknown and verbatim blocks untouched",
+			input:    "#+begin_sidebar\nx\n#+end_sidebar\n#+begin_example\n#+begin_note\n#+end_example",
+			expected: "#+begin_sidebar\nx\n#+end_sidebar\n#+begin_example\n#+begin_note\n#+end_example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := processOrgSpecialBlocks(tt.input, tt.styles)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
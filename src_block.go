@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	orgSrcBeginRe   = regexp.MustCompile(`(?i)^(\s*)#\+begin_src(?:[ \t]+(.*?))?[ \t]*$`)
+	orgSrcEndRe     = regexp.MustCompile(`(?i)^\s*#\+end_src\s*$`)
+	orgResultsRe    = regexp.MustCompile(`(?i)^\s*#\+RESULTS(?:\[[^\]]*\])?:`)
+	orgCaptionRe    = regexp.MustCompile(`(?i)^\s*#\+CAPTION:[ \t]*(.*?)[ \t]*$`)
+	orgLineNumberRe = regexp.MustCompile(`^[-+]n$`)
+)
+
+// defaultLangAliases maps org babel language names to the names Hatena's
+// syntax highlighter expects.
+var defaultLangAliases = map[string]string{
+	"emacs-lisp":     "lisp",
+	"elisp":          "lisp",
+	"shell":          "sh",
+	"C":              "c",
+	"C++":            "cpp",
+	"c++":            "cpp",
+	"R":              "r",
+	"js":             "javascript",
+	"ts":             "typescript",
+	"ipython":        "python",
+	"jupyter-python": "python",
+	"jupyter-julia":  "julia",
+	"jupyter-R":      "r",
+}
+
+// orgSrcBlock is a parsed #+begin_src line.
+type orgSrcBlock struct {
+	Language string
+	// Switches are the switches other than line numbering, such as -r.
+	Switches []string
+	// LineNumbers is "-n", "+n" or empty; LineNumberStart is the number
+	// given after it, if any.
+	LineNumbers     string
+	LineNumberStart int
+	HeaderArgs      map[string]string
+	// RawHeaderArgs keeps the header arguments in their original order.
+	RawHeaderArgs string
+}
+
+func parseOrgSrcBlock(args string) orgSrcBlock {
+	block := orgSrcBlock{HeaderArgs: map[string]string{}}
+	fields := strings.Fields(args)
+	i := 0
+	if i < len(fields) && !strings.HasPrefix(fields[i], "-") && !strings.HasPrefix(fields[i], "+") && !strings.HasPrefix(fields[i], ":") {
+		block.Language = fields[i]
+		i++
+	}
+
+	for ; i < len(fields) && !strings.HasPrefix(fields[i], ":"); i++ {
+		if orgLineNumberRe.MatchString(fields[i]) {
+			block.LineNumbers = fields[i]
+			if i+1 < len(fields) {
+				if n, err := strconv.Atoi(fields[i+1]); err == nil {
+					block.LineNumberStart = n
+					i++
+				}
+			}
+			continue
+		}
+		block.Switches = append(block.Switches, fields[i])
+	}
+
+	block.RawHeaderArgs = strings.Join(fields[i:], " ")
+	for ; i < len(fields); i++ {
+		key := strings.ToLower(fields[i])
+		var values []string
+		for i+1 < len(fields) && !strings.HasPrefix(fields[i+1], ":") {
+			values = append(values, fields[i+1])
+			i++
+		}
+		block.HeaderArgs[key] = strings.Join(values, " ")
+	}
+	return block
+}
+
+// exports returns the value of :exports, which defaults to "code".
+func (b orgSrcBlock) exports() string {
+	switch exports := b.HeaderArgs[":exports"]; exports {
+	case "none", "results", "both", "code":
+		return exports
+	}
+	return "code"
+}
+
+// tangleFileName returns the file name given by :tangle, if any.
+func (b orgSrcBlock) tangleFileName() string {
+	switch tangle := b.HeaderArgs[":tangle"]; tangle {
+	case "", "yes", "no":
+		return ""
+	default:
+		return strings.Trim(tangle, `"`)
+	}
+}
+
+// processOrgSrcBlocks rewrites source blocks before conversion: languages
+// are mapped through aliases (which take precedence over
+// defaultLangAliases), :exports decides whether the code, its #+RESULTS: or
+// both are published, -n/+n line numbers are written into the code, and a
+// #+NAME:, :tangle file name or #+CAPTION: becomes a title above the block.
+func processOrgSrcBlocks(orgContent string, aliases map[string]string) string {
+	lines := strings.Split(orgContent, "\n")
+	var result []string
+	nextLineNumber := 1
+
+	for i := 0; i < len(lines); i++ {
+		m := orgSrcBeginRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(lines[i])), "#+begin_") {
+				// Copy other blocks verbatim; they may contain src examples
+				end := findOrgBlockEnd(lines, i)
+				result = append(result, lines[i:end+1]...)
+				i = end
+				continue
+			}
+			result = append(result, lines[i])
+			continue
+		}
+
+		indent := m[1]
+		block := parseOrgSrcBlock(m[2])
+		end := i + 1
+		for end < len(lines) && !orgSrcEndRe.MatchString(lines[end]) {
+			end++
+		}
+		if end == len(lines) {
+			// An unterminated block is left for pandoc to deal with
+			result = append(result, lines[i:]...)
+			break
+		}
+		body := lines[i+1 : end]
+
+		resultsStart, resultsEnd := findOrgResults(lines, end+1)
+
+		// Affiliated keywords such as #+NAME: are already in result;
+		// other keywords such as #+OPTIONS: above the block are not its own
+		affiliated := len(result)
+		for affiliated > 0 && orgAffiliatedRe.MatchString(result[affiliated-1]) {
+			affiliated--
+		}
+		title := block.tangleFileName()
+		for _, keyword := range result[affiliated:] {
+			if nm := orgNameRe.FindStringSubmatch(keyword); nm != nil && title == "" {
+				title = nm[1]
+			}
+		}
+		if title == "" {
+			for _, keyword := range result[affiliated:] {
+				if cm := orgCaptionRe.FindStringSubmatch(keyword); cm != nil {
+					title = cm[1]
+				}
+			}
+		}
+
+		exports := block.exports()
+		if exports == "none" || exports == "results" {
+			result = result[:affiliated]
+		} else {
+			if title != "" {
+				// The title replaces the affiliated keywords it came from
+				result = append(result[:affiliated],
+					indent+"#+begin_export html",
+					indent+`<div class="code-title">`+html.EscapeString(title)+`</div>`,
+					indent+"#+end_export")
+			}
+			result = append(result, renderOrgSrcBlock(indent, block, body, aliases, &nextLineNumber)...)
+		}
+
+		next := end + 1
+		if resultsStart >= 0 {
+			if exports == "results" || exports == "both" {
+				result = append(result, lines[end+1:resultsEnd]...)
+			}
+			next = resultsEnd
+		}
+		i = next - 1
+	}
+
+	return strings.Join(result, "\n")
+}
+
+// renderOrgSrcBlock writes the block with its language mapped. Hatena has
+// no way to show line numbers next to a code block, nor markup inside one
+// that survives its highlighter, so -n/+n numbers become part of the code
+// text, as documented in the README.
+func renderOrgSrcBlock(indent string, block orgSrcBlock, body []string, aliases map[string]string, nextLineNumber *int) []string {
+	lang := block.Language
+	if alias, ok := aliases[lang]; ok {
+		lang = alias
+	} else if alias, ok := defaultLangAliases[lang]; ok {
+		lang = alias
+	}
+
+	begin := []string{indent + "#+begin_src"}
+	if lang != "" {
+		begin = append(begin, lang)
+	}
+	begin = append(begin, block.Switches...)
+	if block.RawHeaderArgs != "" {
+		begin = append(begin, block.RawHeaderArgs)
+	}
+	rendered := []string{strings.Join(begin, " ")}
+
+	if block.LineNumbers == "" {
+		rendered = append(rendered, body...)
+		return append(rendered, indent+"#+end_src")
+	}
+
+	start := 1
+	if block.LineNumbers == "+n" {
+		start = *nextLineNumber
+	}
+	if block.LineNumberStart != 0 {
+		if block.LineNumbers == "+n" {
+			start += block.LineNumberStart - 1
+		} else {
+			start = block.LineNumberStart
+		}
+	}
+	width := len(strconv.Itoa(start + len(body) - 1))
+	for n, line := range body {
+		line = strings.TrimPrefix(line, indent)
+		// Numbers go in front of the line, so org's comma escaping of
+		// leading "*" and "#+" is no longer needed.
+		if trimmed := strings.TrimLeft(line, " \t"); strings.HasPrefix(trimmed, ",*") || strings.HasPrefix(trimmed, ",#+") {
+			line = line[:len(line)-len(trimmed)] + trimmed[1:]
+		}
+		rendered = append(rendered, fmt.Sprintf("%s%*d: %s", indent, width, start+n, line))
+	}
+	*nextLineNumber = start + len(body)
+
+	return append(rendered, indent+"#+end_src")
+}
+
+// findOrgResults returns the line range of a #+RESULTS: element following
+// a source block that ends just before from, or -1 if there is none.
+func findOrgResults(lines []string, from int) (int, int) {
+	i := from
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	if i >= len(lines) || !orgResultsRe.MatchString(lines[i]) {
+		return -1, -1
+	}
+
+	end := i + 1
+	if end < len(lines) && strings.HasPrefix(strings.ToLower(strings.TrimSpace(lines[end])), "#+begin_") {
+		return i, findOrgBlockEnd(lines, end) + 1
+	}
+	if end < len(lines) && strings.EqualFold(strings.TrimSpace(lines[end]), ":results:") {
+		for end < len(lines) && !strings.EqualFold(strings.TrimSpace(lines[end]), ":end:") {
+			end++
+		}
+		if end < len(lines) {
+			end++
+		}
+		return i, end
+	}
+	if end < len(lines) && orgFixedWidthRe.MatchString(lines[end]) {
+		for end < len(lines) && orgFixedWidthRe.MatchString(lines[end]) {
+			end++
+		}
+		return i, end
+	}
+	// Other results, such as tables and lists, run to the next blank line
+	for end < len(lines) && strings.TrimSpace(lines[end]) != "" && !isOrgHeading(lines[end]) {
+		end++
+	}
+	return i, end
+}
+
+// findOrgBlockEnd returns the index of the #+end_ line closing the block
+// opened at start, or the last line if it is unterminated.
+func findOrgBlockEnd(lines []string, start int) int {
+	inBlock := false
+	for i := start; i < len(lines); i++ {
+		isOrgBlockBoundary(lines[i], &inBlock)
+		if !inBlock {
+			return i
+		}
+	}
+	return len(lines) - 1
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOrgSrcBlock(t *testing.T) {
+	block := parseOrgSrcBlock("emacs-lisp -n 10 -r :tangle init.el :exports both :results output silent")
+
+	if block.Language != "emacs-lisp" {
+		t.Errorf("Expected language emacs-lisp, got %q", block.Language)
+	}
+	if block.LineNumbers != "-n" || block.LineNumberStart != 10 {
+		t.Errorf("Expected -n 10, got %q %d", block.LineNumbers, block.LineNumberStart)
+	}
+	if !reflect.DeepEqual(block.Switches, []string{"-r"}) {
+		t.Errorf("Expected switches [-r], got %v", block.Switches)
+	}
+	if block.exports() != "both" {
+		t.Errorf("Expected exports both, got %q", block.exports())
+	}
+	if block.tangleFileName() != "init.el" {
+		t.Errorf("Expected tangle file init.el, got %q", block.tangleFileName())
+	}
+	if block.HeaderArgs[":results"] != "output silent" {
+		t.Errorf("Expected results 'output silent', got %q", block.HeaderArgs[":results"])
+	}
+}
+
+func TestProcessOrgSrcBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		aliases  map[string]string
+		expected string
+	}{
+		{
+			name:     "default language alias",
+			input:    "#+begin_src emacs-lisp\n(message \"hi\")\n#+end_src",
+			expected: "#+begin_src lisp\n(message \"hi\")\n#+end_src",
+		},
+		{
+			name:     "configured alias overrides default",
+			input:    "#+BEGIN_SRC C++ :exports code\nint x;\n#+END_SRC",
+			aliases:  map[string]string{"C++": "c++"},
+			expected: "#+begin_src c++ :exports code\nint x;\n#+end_src",
+		},
+		{
+			name:     "name becomes title",
+			input:    "#+NAME: main.go\n#+begin_src go\npackage main\n#+end_src",
+			expected: "#+begin_export html\n<div class=\"code-title\">main.go</div>\n#+end_export\n#+begin_src go\npackage main\n#+end_src",
+		},
+		{
+			name:     "file keywords above a hidden block kept",
+			input:    "#+TITLE: My post\n#+OPTIONS: ^:nil\n#+begin_src go :exports none\nx\n#+end_src\ntext a_b",
+			expected: "#+TITLE: My post\n#+OPTIONS: ^:nil\ntext a_b",
+		},
+		{
+			name:     "file keywords above a titled block kept",
+			input:    "#+OPTIONS: ^:nil\n#+NAME: main.go\n#+begin_src go\npackage main\n#+end_src",
+			expected: "#+OPTIONS: ^:nil\n#+begin_export html\n<div class=\"code-title\">main.go</div>\n#+end_export\n#+begin_src go\npackage main\n#+end_src",
+		},
+		{
+			name:     "tangle file name becomes title",
+			input:    "#+begin_src sh :tangle \"scripts/setup.sh\"\necho hi\n#+end_src",
+			expected: "#+begin_export html\n<div class=\"code-title\">scripts/setup.sh</div>\n#+end_export\n#+begin_src sh :tangle \"scripts/setup.sh\"\necho hi\n#+end_src",
+		},
+		{
+			name:     "caption becomes title",
+			input:    "#+CAPTION: <設定例>\n#+begin_src yaml\na: 1\n#+end_src",
+			expected: "#+begin_export html\n<div class=\"code-title\">&lt;設定例&gt;</div>\n#+end_export\n#+begin_src yaml\na: 1\n#+end_src",
+		},
+		{
+			name:     "line numbers",
+			input:    "#+begin_src python -n\na = 1\nb = 2\n#+end_src\ntext\n#+begin_src python +n\nc = 3\n#+end_src\n#+begin_src python -n 9\n,* x\ny\n#+end_src",
+			expected: "#+begin_src python\n1: a = 1\n2: b = 2\n#+end_src\ntext\n#+begin_src python\n3: c = 3\n#+end_src\n#+begin_src python\n 9: * x\n10: y\n#+end_src",
+		},
+		{
+			name:     "exports code drops results",
+			input:    "#+begin_src sh\necho hi\n#+end_src\n\n#+RESULTS:\n: hi\n\nafter",
+			expected: "#+begin_src sh\necho hi\n#+end_src\n\nafter",
+		},
+		{
+			name:     "exports results drops code",
+			input:    "#+NAME: greeting\n#+begin_src sh :exports results\necho hi\n#+end_src\n\n#+RESULTS: greeting\n: hi\n\nafter",
+			expected: "\n#+RESULTS: greeting\n: hi\n\nafter",
+		},
+		{
+			name:     "exports both keeps block results",
+			input:    "#+begin_src python :exports both :results output\nprint(1)\n#+end_src\n\n#+RESULTS:\n#+begin_example\n1\n#+end_example\nafter",
+			expected: "#+begin_src python :exports both :results output\nprint(1)\n#+end_src\n\n#+RESULTS:\n#+begin_example\n1\n#+end_example\nafter",
+		},
+		{
+			name:     "exports none drops everything",
+			input:    "before\n#+begin_src sh :exports none\nsecret\n#+end_src\n#+RESULTS:\n: out\nafter",
+			expected: "before\nafter",
+		},
+		{
+			name:     "src inside other blocks untouched",
+			input:    "#+begin_example\n#+begin_src emacs-lisp\n#+end_src\n#+end_example",
+			expected: "#+begin_example\n#+begin_src emacs-lisp\n#+end_src\n#+end_example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := processOrgSrcBlocks(tt.input, tt.aliases)
+			if result != tt.expected {
+				t.Errorf("Expected:\n%q\nGot:\n%q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// orgPostTag and the properties below mark a headline as a post of its own,
+// in the style of ox-hugo's one-file-many-posts workflow.
+const (
+	orgPostTag             = "EXPORT_HATENA_POST"
+	orgPostProperty        = "EXPORT_HATENA_POST"
+	orgFileNameProperty    = "EXPORT_FILE_NAME"
+	orgTitleProperty       = "EXPORT_TITLE"
+	orgDateProperty        = "EXPORT_DATE"
+	orgDraftProperty       = "EXPORT_HATENA_DRAFT"
+	orgCustomURLProperty   = "EXPORT_HATENA_CUSTOM_URL"
+	orgCategoriesProperty  = "EXPORT_HATENA_CATEGORIES"
+	orgDescriptionProperty = "EXPORT_DESCRIPTION"
+)
+
+// orgSettingKeywords are the file-level keywords copied into every subtree
+// post: those that configure the export or feed macros, but never those
+// such as #+INCLUDE: or #+TOC: that produce content of their own.
+var orgSettingKeywords = map[string]bool{
+	"AUTHOR":       true,
+	"BIND":         true,
+	"DATE":         true,
+	"EMAIL":        true,
+	"EXCLUDE_TAGS": true,
+	"FILETAGS":     true,
+	"LANGUAGE":     true,
+	"LINK":         true,
+	"MACRO":        true,
+	"OPTIONS":      true,
+	"PROPERTY":     true,
+	"SELECT_TAGS":  true,
+	"SEQ_TODO":     true,
+	"SETUPFILE":    true,
+	"STARTUP":      true,
+	"TODO":         true,
+	"TYP_TODO":     true,
+}
+
+// orgSubtreePost is a single post taken from a subtree of an org file.
+type orgSubtreePost struct {
+	Heading    *orgHeading
+	Title      string
+	Categories []string
+	IsDraft    bool
+	Date       time.Time
+	CustomURL  string
+	// Description is the :EXPORT_DESCRIPTION: or :DESCRIPTION: property.
+	Description string
+	// Eyecatch is the :EXPORT_HATENA_EYECATCH: property.
+	Eyecatch string
+	// Author is the :EXPORT_AUTHOR: property.
+	Author string
+	// Content is the org source to convert: the post's title and the
+	// file-level orgSettingKeywords followed by the subtree body with its headlines
+	// promoted.
+	Content string
+}
+
+func isPostHeading(h *orgHeading) bool {
+	if h.hasTag(orgPostTag) {
+		return true
+	}
+	if _, ok := h.Properties[orgFileNameProperty]; ok {
+		return true
+	}
+	value, ok := h.Properties[orgPostProperty]
+	return ok && value != "nil"
+}
+
+// findPostHeadings returns the headlines that are marked as posts.
+func findPostHeadings(headings []*orgHeading) []*orgHeading {
+	var posts []*orgHeading
+	for _, h := range headings {
+		if isPostHeading(h) {
+			posts = append(posts, h)
+		}
+	}
+	return posts
+}
+
+// selectPostHeading picks the post headline matching selector, which may be
+// a line number inside the subtree, an :ID:/:CUSTOM_ID: property or the
+// headline title.
+func selectPostHeading(posts []*orgHeading, selector string) (*orgHeading, error) {
+	if line, err := strconv.Atoi(selector); err == nil {
+		var found *orgHeading
+		for _, h := range posts {
+			// Prefer the innermost post containing the line
+			if h.start < line && line <= h.end {
+				found = h
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("no post subtree contains line %d", line)
+		}
+		return found, nil
+	}
+
+	id := strings.TrimPrefix(strings.TrimPrefix(selector, "id:"), "#")
+	for _, h := range posts {
+		if h.Properties["ID"] == id || h.Properties["CUSTOM_ID"] == id {
+			return h, nil
+		}
+	}
+	for _, h := range posts {
+		if h.Title == selector {
+			return h, nil
+		}
+	}
+	return nil, fmt.Errorf("no post subtree matches %q", selector)
+}
+
+// buildSubtreePost extracts the post rooted at h from lines. Other posts
+// nested in h are left out of its body, as in ox-hugo, and the tags that
+// only drive the export are not made categories.
+func buildSubtreePost(lines []string, h *orgHeading, posts []*orgHeading, fileTags []string) (*orgSubtreePost, error) {
+	post := &orgSubtreePost{
+		Heading:   h,
+		Title:     h.Title,
+		CustomURL: h.Properties[orgCustomURLProperty],
+	}
+	if title := h.Properties[orgTitleProperty]; title != "" {
+		post.Title = title
+	}
+	if post.CustomURL == "" {
+		post.CustomURL = h.Properties[orgFileNameProperty]
+	}
+	post.Eyecatch = h.Properties[orgEyecatchProperty]
+	post.Author = h.Properties[orgAuthorProperty]
+	post.Description = h.Properties[orgDescriptionProperty]
+	if post.Description == "" {
+		post.Description = h.Properties["DESCRIPTION"]
+	}
+
+	switch strings.ToLower(h.Properties[orgDraftProperty]) {
+	case "t", "true", "yes":
+		post.IsDraft = true
+	}
+
+	if date := h.Properties[orgDateProperty]; date != "" {
+		t, err := parseOrgDate(date)
+		if err != nil {
+			return nil, fmt.Errorf("subtree %q: %v", h.Title, err)
+		}
+		post.Date = t
+	}
+
+	exportOpts := parseOrgExportOptions(lines)
+	exportTags := append([]string{orgPostTag, "ATTACH", "export", "noexport"}, exportOpts.SelectTags...)
+	exportTags = append(exportTags, exportOpts.ExcludeTags...)
+	seen := map[string]bool{}
+	addCategory := func(category string) {
+		if category == "" || seen[category] {
+			return
+		}
+		for _, tag := range exportTags {
+			if strings.EqualFold(category, tag) {
+				return
+			}
+		}
+		seen[category] = true
+		post.Categories = append(post.Categories, category)
+	}
+	for _, tag := range fileTags {
+		addCategory(tag)
+	}
+	for _, tag := range h.inheritedTags() {
+		addCategory(tag)
+	}
+	for _, category := range splitOrgTags(h.Properties[orgCategoriesProperty]) {
+		addCategory(category)
+	}
+
+	// The post's own title, and date if it has one, replace the file-level
+	// keywords so that {{{title}}} and {{{date}}} refer to the post.
+	content := []string{"#+TITLE: " + post.Title}
+	date := h.Properties[orgDateProperty]
+	if date != "" {
+		content = append(content, "#+DATE: "+date)
+	}
+	for _, keyword := range orgFileKeywordLines(lines) {
+		m := orgKeywordRe.FindStringSubmatch(keyword)
+		name := strings.ToUpper(m[1])
+		if !orgSettingKeywords[name] || (date != "" && name == "DATE") {
+			continue
+		}
+		content = append(content, keyword)
+	}
+	var body []string
+	for i := h.bodyStart; i < h.end; i++ {
+		if nested := nestedPostAt(posts, h, i); nested != nil {
+			i = nested.end - 1
+			continue
+		}
+		body = append(body, lines[i])
+	}
+	content = append(content, shiftOrgHeadings(body, -h.Level)...)
+	post.Content = strings.Join(content, "\n")
+
+	return post, nil
+}
+
+// nestedPostAt returns the post other than h whose headline is at line i.
+func nestedPostAt(posts []*orgHeading, h *orgHeading, i int) *orgHeading {
+	for _, p := range posts {
+		if p != h && p.start == i {
+			return p
+		}
+	}
+	return nil
+}
+
+// extractSubtreePosts returns the posts selected from an org document. If
+// all is true every post subtree is returned; otherwise the one matching
+// selector.
+func extractSubtreePosts(orgContent string, selector string, all bool, fileTags []string) ([]*orgSubtreePost, error) {
+	lines := strings.Split(orgContent, "\n")
+	headings := parseOrgHeadings(lines)
+	allPosts := findPostHeadings(headings)
+	posts := allPosts
+	if len(posts) == 0 {
+		return nil, fmt.Errorf("no subtree is marked with :%s: or :%s:", orgPostTag, orgFileNameProperty)
+	}
+
+	if all {
+		// Posts the export options leave out are never published: those
+		// under COMMENT, :noexport: or excluded task headlines, and those
+		// outside the select tags when some headline has one
+		exportOpts := parseOrgExportOptions(lines)
+		selected := selectedHeadings(headings, exportOpts.SelectTags)
+		var exported []*orgHeading
+		for _, h := range posts {
+			if !exportOpts.isExcludedHeading(h, lines) && (selected == nil || selected[h]) {
+				exported = append(exported, h)
+			}
+		}
+		posts = exported
+	} else {
+		h, err := selectPostHeading(posts, selector)
+		if err != nil {
+			return nil, err
+		}
+		posts = []*orgHeading{h}
+	}
+
+	var result []*orgSubtreePost
+	for _, h := range posts {
+		post, err := buildSubtreePost(lines, h, allPosts, fileTags)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, post)
+	}
+	return result, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const subtreeTestOrg = `#+title: My blog
+#+filetags: :blog:
+#+options: toc:nil
+
+* Emacs :emacs:
+** First post :EXPORT_HATENA_POST:go:
+:PROPERTIES:
+:ID:       AAAA-1111
+:EXPORT_DATE: 2024-01-02
+:EXPORT_HATENA_DRAFT: t
+:EXPORT_HATENA_CUSTOM_URL: first-post
+:EXPORT_HATENA_EYECATCH: images/first.png
+:EXPORT_AUTHOR: taro
+:END:
+Body of the first post.
+*** Details
+More text.
+** Second post
+:PROPERTIES:
+:EXPORT_FILE_NAME: second
+:DESCRIPTION: The second post.
+:END:
+Body of the second post.
+* Notes
+Not a post.`
+
+func TestExtractSubtreePostsAll(t *testing.T) {
+	posts, err := extractSubtreePosts(subtreeTestOrg, "", true, []string{"blog"})
+	if err != nil {
+		t.Fatalf("extractSubtreePosts failed: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("Expected 2 posts, got %d", len(posts))
+	}
+
+	first := posts[0]
+	if first.Title != "First post" {
+		t.Errorf("Expected title %q, got %q", "First post", first.Title)
+	}
+	if !reflect.DeepEqual(first.Categories, []string{"blog", "emacs", "go"}) {
+		t.Errorf("Expected categories [blog emacs go], got %v", first.Categories)
+	}
+	if !first.IsDraft {
+		t.Error("Expected first post to be a draft")
+	}
+	if !first.Date.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Unexpected date %v", first.Date)
+	}
+	if first.CustomURL != "first-post" {
+		t.Errorf("Expected custom URL %q, got %q", "first-post", first.CustomURL)
+	}
+
+	expectedContent := "#+TITLE: First post\n#+DATE: 2024-01-02\n#+filetags: :blog:\n#+options: toc:nil\nBody of the first post.\n* Details\nMore text."
+	if first.Content != expectedContent {
+		t.Errorf("Expected content:\n%q\nGot:\n%q", expectedContent, first.Content)
+	}
+
+	second := posts[1]
+	if second.IsDraft {
+		t.Error("Expected second post not to be a draft")
+	}
+	if second.CustomURL != "second" {
+		t.Errorf("Expected EXPORT_FILE_NAME to be used as custom URL, got %q", second.CustomURL)
+	}
+	if first.Author != "taro" || second.Author != "" {
+		t.Errorf("Unexpected authors %q and %q", first.Author, second.Author)
+	}
+	if first.Eyecatch != "images/first.png" || second.Eyecatch != "" {
+		t.Errorf("Unexpected eyecatches %q and %q", first.Eyecatch, second.Eyecatch)
+	}
+	if first.Description != "" || second.Description != "The second post." {
+		t.Errorf("Unexpected descriptions %q and %q", first.Description, second.Description)
+	}
+	if strings.Contains(second.Content, "Not a post") {
+		t.Error("Second post should not include the following top-level subtree")
+	}
+}
+
+func TestExtractSubtreePostsSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector string
+		expected string
+	}{
+		{name: "by ID", selector: "AAAA-1111", expected: "First post"},
+		{name: "by id: link", selector: "id:AAAA-1111", expected: "First post"},
+		{name: "by title", selector: "Second post", expected: "Second post"},
+		{name: "by headline line", selector: "6", expected: "First post"},
+		{name: "by line inside subtree", selector: "15", expected: "First post"},
+		{name: "by line in second subtree", selector: "20", expected: "Second post"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts, err := extractSubtreePosts(subtreeTestOrg, tt.selector, false, nil)
+			if err != nil {
+				t.Fatalf("extractSubtreePosts failed: %v", err)
+			}
+			if len(posts) != 1 || posts[0].Title != tt.expected {
+				t.Errorf("Expected post %q, got %+v", tt.expected, posts)
+			}
+		})
+	}
+}
+
+func TestExtractSubtreePostsErrors(t *testing.T) {
+	if _, err := extractSubtreePosts(subtreeTestOrg, "Notes", false, nil); err == nil {
+		t.Error("Expected error for a heading that is not a post")
+	}
+	if _, err := extractSubtreePosts(subtreeTestOrg, "2", false, nil); err == nil {
+		t.Error("Expected error for a line outside any post")
+	}
+	if _, err := extractSubtreePosts("* Just a heading\ntext", "", true, nil); err == nil {
+		t.Error("Expected error when no subtree is marked as a post")
+	}
+}
+
+func TestExtractSubtreePostsSkipsExcluded(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name: "COMMENT and exclude tags",
+			content: `* Published :EXPORT_HATENA_POST:
+text
+* Hidden :EXPORT_HATENA_POST:noexport:
+secret
+* COMMENT Drafts
+** Idea :EXPORT_HATENA_POST:
+secret`,
+			expected: []string{"Published"},
+		},
+		{
+			name: "COMMENT after a custom todo keyword",
+			content: `#+TODO: TODO WAIT | DONE
+* WAIT COMMENT Later :EXPORT_HATENA_POST:
+* Published :EXPORT_HATENA_POST:`,
+			expected: []string{"Published"},
+		},
+		{
+			name: "tasks left out",
+			content: `#+OPTIONS: tasks:nil
+* TODO Half-written :EXPORT_HATENA_POST:
+* TODO Series
+** Part :EXPORT_HATENA_POST:
+* Published :EXPORT_HATENA_POST:`,
+			expected: []string{"Published"},
+		},
+		{
+			name: "only done tasks",
+			content: `#+TODO: TODO WAIT | DONE
+#+OPTIONS: tasks:done
+* WAIT Open :EXPORT_HATENA_POST:
+* DONE Finished :EXPORT_HATENA_POST:
+* Plain :EXPORT_HATENA_POST:`,
+			expected: []string{"Finished", "Plain"},
+		},
+		{
+			name: "select tags",
+			content: `#+SELECT_TAGS: publish
+* Ready :EXPORT_HATENA_POST:publish:
+* Series :publish:
+** Part :EXPORT_HATENA_POST:
+* Unselected :EXPORT_HATENA_POST:`,
+			expected: []string{"Ready", "Part"},
+		},
+	}
+
+	for _, tt := range tests {
+		posts, err := extractSubtreePosts(tt.content, "", true, nil)
+		if err != nil {
+			t.Errorf("%s: extractSubtreePosts failed: %v", tt.name, err)
+			continue
+		}
+		var titles []string
+		for _, post := range posts {
+			titles = append(titles, post.Title)
+		}
+		if !reflect.DeepEqual(titles, tt.expected) {
+			t.Errorf("%s: expected posts %v, got %v", tt.name, tt.expected, titles)
+		}
+	}
+}
+
+func TestExtractSubtreePostsNested(t *testing.T) {
+	content := `#+EXCLUDE_TAGS: private
+* Series :EXPORT_HATENA_POST:emacs:ATTACH:export:
+Introduction.
+** Part 1 :EXPORT_HATENA_POST:
+Part 1 body.
+*** Part 1 details
+** Links
+See the parts.
+*** Private notes :private:
+secret`
+
+	posts, err := extractSubtreePosts(content, "", true, nil)
+	if err != nil {
+		t.Fatalf("extractSubtreePosts failed: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("Expected 2 posts, got %d", len(posts))
+	}
+
+	series := posts[0]
+	expected := "#+TITLE: Series\n#+EXCLUDE_TAGS: private\nIntroduction.\n* Links\nSee the parts.\n** Private notes :private:\nsecret"
+	if series.Content != expected {
+		t.Errorf("Expected content:\n%q\nGot:\n%q", expected, series.Content)
+	}
+	if !reflect.DeepEqual(series.Categories, []string{"emacs"}) {
+		t.Errorf("Expected categories [emacs], got %v", series.Categories)
+	}
+	if !strings.Contains(posts[1].Content, "Part 1 body.") {
+		t.Errorf("Nested post should keep its own body, got %q", posts[1].Content)
+	}
+	if !reflect.DeepEqual(posts[1].Categories, []string{"emacs"}) {
+		t.Errorf("Expected inherited categories [emacs], got %v", posts[1].Categories)
+	}
+}
+
+func TestExtractSubtreePostsSettingKeywords(t *testing.T) {
+	content := `#+TITLE: Blog
+#+MACRO: greet Hello, $1
+#+INCLUDE: "header.org"
+#+TOC: headlines 2
+#+DESCRIPTION: The whole blog
+#+setupfile: setup.org
+* Post :EXPORT_HATENA_POST:
+{{{greet(world)}}}`
+
+	posts, err := extractSubtreePosts(content, "Post", false, nil)
+	if err != nil {
+		t.Fatalf("extractSubtreePosts failed: %v", err)
+	}
+	expected := "#+TITLE: Post\n#+MACRO: greet Hello, $1\n#+setupfile: setup.org\n{{{greet(world)}}}"
+	if posts[0].Content != expected {
+		t.Errorf("Expected content:\n%q\nGot:\n%q", expected, posts[0].Content)
+	}
+}
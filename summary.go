@@ -0,0 +1,141 @@
+package main
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	htmlDroppedElementRe = regexp.MustCompile(`(?is)<(pre|script|style|figure|h[1-6])\b.*?</(?:pre|script|style|figure|h[1-6])>`)
+	htmlCommentRe        = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlBlockEndRe       = regexp.MustCompile(`(?i)</(?:p|div|li|blockquote|tr|dd|dt)>|<br\s*/?>`)
+	htmlTagRe            = regexp.MustCompile(`</?[A-Za-z][^<>]*>`)
+
+	mdImageRe        = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	mdLinkRe         = regexp.MustCompile(`\[([^\]]*)\](?:\([^)]*\)|\[[^\]]*\])`)
+	mdFootnoteRefRe  = regexp.MustCompile(`\[\^[^\]]+\]`)
+	mdAttributesRe   = regexp.MustCompile(`\{[#.][^{}]*\}`)
+	mdEmphasisRe     = regexp.MustCompile(`\*+|~~`)
+	mdInlineCodeRe   = regexp.MustCompile("`+([^`]*)`+")
+	mdEscapeRe       = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!<>~|$"'])`)
+	mdBlockPrefixRe  = regexp.MustCompile(`^\s*(?:>\s*)*(?:[-*+]\s+|\d+[.)]\s+)?`)
+	mdSkippedLineRe  = regexp.MustCompile(`^\s*(?:#|\||\[[^\]]+\]:|(?:[-*_]\s*){3,}$)`)
+	hatenaNotationRe = regexp.MustCompile(`\[:contents\]|\[[^\[\]\s]+:embed(?::cite)?\]|\(\([^()]*\)\)`)
+	spacesRe         = regexp.MustCompile(`[ \t]+`)
+)
+
+// entrySummary returns the summary of an entry: description with its lines
+// joined, or when there is none and length is positive, the first length
+// characters of the converted content as plain text.
+func entrySummary(description, content, format string, length int) string {
+	if summary := joinParagraphLines(description); summary != "" {
+		return summary
+	}
+	if length <= 0 {
+		return ""
+	}
+	return truncateSummary(plainText(content, format), length)
+}
+
+// plainText strips the markup from converted markdown or HTML, leaving the
+// text of the paragraphs, lists and quotes. Headings, code blocks, figures
+// and Hatena notations such as footnotes are dropped.
+func plainText(content, format string) string {
+	if format != FormatHTML {
+		content = markdownText(content)
+	}
+
+	content = htmlCommentRe.ReplaceAllString(content, "")
+	content = htmlDroppedElementRe.ReplaceAllString(content, "")
+	content = htmlBlockEndRe.ReplaceAllString(content, "\n\n")
+	content = htmlTagRe.ReplaceAllString(content, "")
+	content = html.UnescapeString(content)
+	content = hatenaNotationRe.ReplaceAllString(content, "")
+	return joinParagraphLines(content)
+}
+
+// markdownText strips markdown syntax line by line.
+func markdownText(markdown string) string {
+	var result []string
+	fence := ""
+	for _, line := range strings.Split(htmlCommentRe.ReplaceAllString(markdown, ""), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if run := backtickRun(trimmed, 0); run >= 3 {
+			fence = trimmed[:run]
+			continue
+		}
+		if strings.HasPrefix(trimmed, "~~~") {
+			fence = "~~~"
+			continue
+		}
+		if mdSkippedLineRe.MatchString(line) {
+			result = append(result, "")
+			continue
+		}
+
+		line = mdBlockPrefixRe.ReplaceAllString(line, "")
+		line = mdImageRe.ReplaceAllString(line, "")
+		line = mdFootnoteRefRe.ReplaceAllString(line, "")
+		line = mdLinkRe.ReplaceAllString(line, "$1")
+		line = mdAttributesRe.ReplaceAllString(line, "")
+		line = mdInlineCodeRe.ReplaceAllString(line, "$1")
+		line = mdEmphasisRe.ReplaceAllString(line, "")
+		line = mdEscapeRe.ReplaceAllString(line, "$1")
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// joinParagraphLines joins the lines of text into a single line, without
+// spaces between CJK characters.
+func joinParagraphLines(text string) string {
+	joined := ""
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(spacesRe.ReplaceAllString(line, " "))
+		if line == "" {
+			continue
+		}
+		if joined == "" {
+			joined = line
+		} else {
+			joined = joinLines(joined, line)
+		}
+	}
+	return joined
+}
+
+// truncateSummary cuts text to at most length characters followed by "…".
+// Latin words are not split, but CJK text, which has no spaces, is cut
+// anywhere; trailing punctuation such as "、" is dropped.
+func truncateSummary(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	cut := length
+	if isWordRune(runes[cut-1]) && isWordRune(runes[cut]) {
+		for i := cut - 1; i > length/2; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+	}
+	summary := strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return summary + "…"
+}
+
+func isWordRune(r rune) bool {
+	return !isCJK(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
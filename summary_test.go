@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestEntrySummary(t *testing.T) {
+	markdown := "# Title\n\n" +
+		"これは**最初の**段落です。\n[リンク](https://example.com)も含みます。\n\n" +
+		"```go\nfmt.Println(\"code\")\n```\n\n" +
+		"- 項目((脚注))\n- `code` item\n"
+	html := `<h3 id="x">Title</h3>
+<p>Hello <a href="https://example.com">world</a> &amp; more.</p>
+<pre class="code"><code>skipped</code></pre>
+<figure class="figure-image"><img src="a.png"><figcaption>Caption</figcaption></figure>
+<p>Second<br>line</p>`
+
+	tests := []struct {
+		name        string
+		description string
+		content     string
+		format      string
+		length      int
+		expected    string
+	}{
+		{"description", "First line\nsecond line", markdown, FormatMarkdown, 0, "First line second line"},
+		{"CJK description", "一行目\n二行目", markdown, FormatMarkdown, 10, "一行目二行目"},
+		{"no description", "", markdown, FormatMarkdown, 0, ""},
+		{"markdown", "", markdown, FormatMarkdown, 100, "これは最初の段落です。リンクも含みます。項目code item"},
+		{"markdown truncated", "", markdown, FormatMarkdown, 11, "これは最初の段落です…"},
+		{"HTML", "", html, FormatHTML, 100, "Hello world & more. Second line"},
+	}
+
+	for _, test := range tests {
+		summary := entrySummary(test.description, test.content, test.format, test.length)
+		if summary != test.expected {
+			t.Errorf("%s: got %q, expected %q", test.name, summary, test.expected)
+		}
+	}
+}
+
+func TestTruncateSummary(t *testing.T) {
+	tests := []struct {
+		text     string
+		length   int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"The quick brown fox jumps", 13, "The quick…"},
+		{"Supercalifragilistic", 5, "Super…"},
+		{"日本語の文章を、途中で切ります", 8, "日本語の文章を…"},
+		{"Go言語でブログを書く", 4, "Go言語…"},
+	}
+
+	for _, test := range tests {
+		if summary := truncateSummary(test.text, test.length); summary != test.expected {
+			t.Errorf("truncateSummary(%q, %d) = %q, expected %q", test.text, test.length, summary, test.expected)
+		}
+	}
+}